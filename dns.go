@@ -0,0 +1,505 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+/*
+
+	url.URL dns resolution of the host into the CNAME chain and the
+	A|AAAA addresses, each annotated with IsPrivate and a class, through
+	a pluggable Resolver with a ttl respecting cache
+
+	res, err := url.Resolve(ctx, &u)
+	for _, a := range res.Addrs {
+		fmt.Println(a.IP, a.Class, a.Private)
+	}
+
+	dns := url.NewDNS(&url.ServerResolver{Server: "127.0.0.1:5353"})
+	res, err = dns.Resolve(ctx, &u)
+
+*/
+
+// ErrNoAddress reports a host without A or AAAA records
+var ErrNoAddress = errors.New("url: no addresses for host")
+
+// IPClass is the range an address belongs to
+type IPClass int
+
+// IPClass ranges
+const (
+	IPPublic      IPClass = iota
+	IPPrivate             // RFC 1918, RFC 4193
+	IPLoopback            // 127/8, ::1
+	IPLinkLocal           // 169.254/16, fe80::/10; includes cloud metadata
+	IPUnspecified         // 0.0.0.0, ::
+	IPMulticast           // 224/4, ff00::/8
+	IPShared              // 100.64/10 carrier grade nat, RFC 6598
+	IPReserved            // 0/8, documentation, benchmarking, 240/4, ...
+	IPInvalid
+)
+
+var ipClassNames = [...]string{"public", "private", "loopback", "link-local",
+	"unspecified", "multicast", "shared", "reserved", "invalid"}
+
+func (c IPClass) String() string {
+	if c < 0 || int(c) >= len(ipClassNames) {
+		return fmt.Sprintf("class(%d)", int(c))
+	}
+	return ipClassNames[c]
+}
+
+// reserved special purpose ranges not covered by the net.IP methods
+var sharedNet, reservedNets = mustCIDR("100.64.0.0/10")[0], mustCIDR(
+	"0.0.0.0/8", "192.0.0.0/24", "192.0.2.0/24", "198.18.0.0/15",
+	"198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+	"100::/64", "2001:db8::/32", "2001::/23",
+)
+
+func mustCIDR(cidrs ...string) (nets []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// nat64 well-known prefix 64:ff9b::/96, RFC 6052, and 6to4 2002::/16, RFC 3056
+var nat64Net, sixToFourNet = mustCIDR("64:ff9b::/96")[0], mustCIDR("2002::/16")[0]
+
+// embeddedIPv4 returns the ipv4 address carried by a nat64 or 6to4
+// ipv6 address, or nil
+func embeddedIPv4(ip net.IP) net.IP {
+	if len(ip) != net.IPv6len || ip.To4() != nil {
+		return nil
+	}
+	switch {
+	case nat64Net.Contains(ip):
+		return net.IPv4(ip[12], ip[13], ip[14], ip[15])
+	case sixToFourNet.Contains(ip):
+		return net.IPv4(ip[2], ip[3], ip[4], ip[5])
+	}
+	return nil
+}
+
+// ClassifyIP returns the range of the address; nat64 and 6to4 addresses
+// are classified by the embedded ipv4 address
+func ClassifyIP(ip net.IP) IPClass {
+	if v4 := embeddedIPv4(ip); v4 != nil {
+		ip = v4
+	}
+	switch {
+	case ip == nil:
+		return IPInvalid
+	case ip.IsUnspecified():
+		return IPUnspecified
+	case ip.IsLoopback():
+		return IPLoopback
+	case ip.IsPrivate():
+		return IPPrivate
+	case ip.IsLinkLocalUnicast():
+		return IPLinkLocal
+	case ip.IsMulticast():
+		return IPMulticast
+	case sharedNet.Contains(ip):
+		return IPShared
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return IPReserved
+		}
+	}
+	return IPPublic
+}
+
+// DNSRecord is an A, AAAA or CNAME answer; names are fully qualified
+type DNSRecord struct {
+	Name   string
+	Type   dnsmessage.Type
+	IP     net.IP // A|AAAA
+	Target string // CNAME
+	TTL    time.Duration
+}
+
+// Resolver returns the A, AAAA and CNAME records of a host
+type Resolver interface {
+	LookupRecords(ctx context.Context, host string) ([]DNSRecord, error)
+}
+
+// ResolvedAddr is an address annotated with IsPrivate and its class
+type ResolvedAddr struct {
+	IP      net.IP
+	Private bool
+	Class   IPClass
+}
+
+// Resolution is the CNAME chain and the addresses of a host; TTL is
+// the lowest of the records used and zero for an ip url
+type Resolution struct {
+	Host   string
+	CNAMEs []string
+	Addrs  []ResolvedAddr
+	TTL    time.Duration
+}
+
+// DNS resolves url hosts through a Resolver and caches the results
+// for the record ttl bounded by MinTTL and MaxTTL; failures are not
+// cached and the zero value resolves with a NetResolver
+type DNS struct {
+	Resolver       Resolver      // default NetResolver
+	MinTTL, MaxTTL time.Duration // defaults 5s and 1h
+
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	res     Resolution
+	expires time.Time
+}
+
+// DefaultDNS resolves with the system resolver
+var DefaultDNS = NewDNS(&NetResolver{})
+
+// Resolve resolves the url host with DefaultDNS
+func Resolve(ctx context.Context, u *URL) (Resolution, error) {
+	return DefaultDNS.Resolve(ctx, u)
+}
+
+// NewDNS returns a caching DNS over the resolver
+func NewDNS(r Resolver) *DNS {
+	return &DNS{
+		Resolver: r,
+		MinTTL:   5 * time.Second,
+		MaxTTL:   time.Hour,
+		now:      time.Now,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+// Resolve returns the CNAME chain and addresses of the url host; an ip
+// url resolves to itself without a lookup
+func (d *DNS) Resolve(ctx context.Context, u *URL) (Resolution, error) {
+
+	if u.IP {
+		ip := net.ParseIP(u.Host)
+		if ip == nil {
+			return Resolution{}, fmt.Errorf("%w: %q", ErrNoAddress, u.Host)
+		}
+		return Resolution{Host: u.Host, Addrs: []ResolvedAddr{annotate(ip)}}, nil
+	}
+
+	host := strings.TrimSuffix(u.Host, ".")
+	d.mu.Lock()
+	e, ok := d.entries[host]
+	if ok && d.clock().Before(e.expires) {
+		d.mu.Unlock()
+		return e.res, nil
+	}
+	d.mu.Unlock()
+
+	r := d.Resolver
+	if r == nil {
+		r = &NetResolver{}
+	}
+	records, err := r.LookupRecords(ctx, host)
+	if err != nil {
+		return Resolution{}, err
+	}
+	res, err := chain(host, records)
+	if err != nil {
+		return res, err
+	}
+
+	minTTL, maxTTL := d.MinTTL, d.MaxTTL
+	if minTTL <= 0 {
+		minTTL = 5 * time.Second
+	}
+	if maxTTL <= 0 {
+		maxTTL = time.Hour
+	}
+	ttl := res.TTL
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	d.mu.Lock()
+	if d.entries == nil {
+		d.entries = make(map[string]dnsEntry)
+	}
+	now := d.clock()
+	d.entries[host] = dnsEntry{res: res, expires: now.Add(ttl)}
+	for k, e := range d.entries { // drop expired entries
+		if !now.Before(e.expires) {
+			delete(d.entries, k)
+		}
+	}
+	d.mu.Unlock()
+
+	return res, nil
+}
+
+// clock returns the current time; now is replaced in tests
+func (d *DNS) clock() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+	return d.now()
+}
+
+// chain follows the CNAME records from the host and collects the
+// addresses of the canonical name
+func chain(host string, records []DNSRecord) (res Resolution, err error) {
+
+	res.Host = host
+	name := fqdn(host)
+	var ttl time.Duration = -1
+	use := func(r DNSRecord) {
+		if ttl < 0 || r.TTL < ttl {
+			ttl = r.TTL
+		}
+	}
+
+next:
+	for hops := 0; hops < 8; hops++ {
+		for _, r := range records {
+			if r.Type == dnsmessage.TypeCNAME && strings.EqualFold(r.Name, name) {
+				use(r)
+				name = fqdn(r.Target)
+				res.CNAMEs = append(res.CNAMEs, strings.TrimSuffix(name, "."))
+				continue next
+			}
+		}
+		break
+	}
+
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		for _, r := range records {
+			if r.Type == t && r.IP != nil && strings.EqualFold(r.Name, name) {
+				use(r)
+				res.Addrs = append(res.Addrs, annotate(r.IP))
+			}
+		}
+	}
+
+	if len(res.Addrs) == 0 {
+		return res, fmt.Errorf("%w: %q", ErrNoAddress, host)
+	}
+	res.TTL = ttl
+
+	return res, nil
+}
+
+func annotate(ip net.IP) ResolvedAddr {
+	private := IsPrivate(ip)
+	if v4 := embeddedIPv4(ip); v4 != nil {
+		private = IsPrivate(v4)
+	}
+	return ResolvedAddr{IP: ip, Private: private, Class: ClassifyIP(ip)}
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// NetResolver resolves with a net.Resolver, default net.DefaultResolver;
+// net.Resolver reports a single canonical name, not the chain, so CNAMEs
+// holds only that name, and as it does not expose ttls all records carry
+// TTL, default 60s; use ServerResolver for the full chain
+type NetResolver struct {
+	Resolver *net.Resolver
+	TTL      time.Duration
+}
+
+// LookupRecords implements Resolver
+func (r *NetResolver) LookupRecords(ctx context.Context, host string) ([]DNSRecord, error) {
+
+	nr, ttl := r.Resolver, r.TTL
+	if nr == nil {
+		nr = net.DefaultResolver
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	name := fqdn(host)
+	var records []DNSRecord
+	if cname, err := nr.LookupCNAME(ctx, host); err == nil && !strings.EqualFold(fqdn(cname), name) {
+		records = append(records, DNSRecord{Name: name, Type: dnsmessage.TypeCNAME, Target: fqdn(cname), TTL: ttl})
+		name = fqdn(cname)
+	}
+
+	addrs, err := nr.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		t := dnsmessage.TypeAAAA
+		if a.IP.To4() != nil {
+			t = dnsmessage.TypeA
+		}
+		records = append(records, DNSRecord{Name: name, Type: t, IP: a.IP, TTL: ttl})
+	}
+
+	return records, nil
+}
+
+// ServerResolver queries a dns server directly over udp reporting the
+// record ttls; truncated responses are used as is
+type ServerResolver struct {
+	Server  string        // host:port
+	Timeout time.Duration // per query; default 2s
+}
+
+// LookupRecords implements Resolver; a NXDOMAIN answer has no records
+func (r *ServerResolver) LookupRecords(ctx context.Context, host string) ([]DNSRecord, error) {
+	var records []DNSRecord
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := r.query(ctx, fqdn(host), t)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, answers...)
+	}
+	return records, nil
+}
+
+// query sends one question and parses the answer section
+func (r *ServerResolver) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]DNSRecord, error) {
+
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	b, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if _, err = conn.Write(b); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil || h.ID != id || !h.Response {
+			continue // not our answer
+		}
+		switch h.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("url: dns %s %s: %v", host, qtype, h.RCode)
+		}
+		if err = p.SkipAllQuestions(); err != nil {
+			return nil, err
+		}
+
+		var records []DNSRecord
+		for {
+			rh, err := p.AnswerHeader()
+			if err == dnsmessage.ErrSectionDone {
+				return records, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			rec := DNSRecord{Name: rh.Name.String(), Type: rh.Type, TTL: time.Duration(rh.TTL) * time.Second}
+			switch rh.Type {
+			case dnsmessage.TypeA:
+				a, err := p.AResource()
+				if err != nil {
+					return nil, err
+				}
+				rec.IP = net.IP(append([]byte(nil), a.A[:]...))
+			case dnsmessage.TypeAAAA:
+				a, err := p.AAAAResource()
+				if err != nil {
+					return nil, err
+				}
+				rec.IP = net.IP(append([]byte(nil), a.AAAA[:]...))
+			case dnsmessage.TypeCNAME:
+				c, err := p.CNAMEResource()
+				if err != nil {
+					return nil, err
+				}
+				rec.Target = c.CNAME.String()
+			default:
+				if err := p.SkipAnswer(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			records = append(records, rec)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zxdev/url/v2"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsStandIn answers A|AAAA queries over udp from a static zone
+type dnsStandIn struct {
+	conn    net.PacketConn
+	queries int32
+	cname   map[string]string
+	a, aaaa map[string]string
+}
+
+func newDNSStandIn(t *testing.T) *dnsStandIn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("udp listen", err)
+	}
+	s := &dnsStandIn{
+		conn: conn,
+		cname: map[string]string{
+			"www.example.com.":  "edge.example.net.",
+			"edge.example.net.": "origin.example.org.",
+		},
+		a: map[string]string{
+			"origin.example.org.":   "93.184.215.14",
+			"intranet.example.com.": "10.0.0.7",
+			"meta.example.com.":     "169.254.169.254",
+		},
+		aaaa: map[string]string{
+			"origin.example.org.": "2606:2800:21f:cb07:6820:80da:af6b:8b2c",
+		},
+	}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *dnsStandIn) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if req.Unpack(buf[:n]) != nil || len(req.Questions) != 1 {
+			continue
+		}
+		atomic.AddInt32(&s.queries, 1)
+		res := s.answer(req)
+		b, _ := res.Pack()
+		s.conn.WriteTo(b, addr)
+	}
+}
+
+func (s *dnsStandIn) answer(req dnsmessage.Message) dnsmessage.Message {
+
+	q := req.Questions[0]
+	res := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true, RecursionDesired: req.RecursionDesired},
+		Questions: req.Questions,
+	}
+
+	name, ttl := strings.ToLower(q.Name.String()), uint32(300)
+	for {
+		target, ok := s.cname[name]
+		if !ok {
+			break
+		}
+		res.Answers = append(res.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: ttl},
+			Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)},
+		})
+		name, ttl = target, ttl/2
+	}
+
+	_, hasA := s.a[name]
+	_, hasAAAA := s.aaaa[name]
+	if !hasA && !hasAAAA {
+		res.RCode = dnsmessage.RCodeNameError
+		return res
+	}
+	h := dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: q.Type, Class: dnsmessage.ClassINET, TTL: 30}
+	switch ip := net.ParseIP(s.a[name]); {
+	case q.Type == dnsmessage.TypeA && hasA:
+		var a dnsmessage.AResource
+		copy(a.A[:], ip.To4())
+		res.Answers = append(res.Answers, dnsmessage.Resource{Header: h, Body: &a})
+	case q.Type == dnsmessage.TypeAAAA && hasAAAA:
+		var a dnsmessage.AAAAResource
+		copy(a.AAAA[:], net.ParseIP(s.aaaa[name]))
+		res.Answers = append(res.Answers, dnsmessage.Resource{Header: h, Body: &a})
+	}
+
+	return res
+}
+
+func TestClassifyIP(t *testing.T) {
+
+	for _, v := range []struct {
+		IP    string
+		Class url.IPClass
+	}{
+		{"93.184.215.14", url.IPPublic},
+		{"10.1.2.3", url.IPPrivate},
+		{"fd00::1", url.IPPrivate},
+		{"127.0.0.1", url.IPLoopback},
+		{"::1", url.IPLoopback},
+		{"169.254.169.254", url.IPLinkLocal},
+		{"0.0.0.0", url.IPUnspecified},
+		{"224.0.0.1", url.IPMulticast},
+		{"100.64.1.1", url.IPShared},
+		{"192.0.2.1", url.IPReserved},
+		{"2001:db8::1", url.IPReserved},
+		{"64:ff9b::a00:1", url.IPPrivate},    // nat64 10.0.0.1
+		{"2002:7f00:1::", url.IPLoopback},    // 6to4 127.0.0.1
+		{"64:ff9b::5db8:d70e", url.IPPublic}, // nat64 93.184.215.14
+		{"bogus", url.IPInvalid},
+	} {
+		if c := url.ClassifyIP(net.ParseIP(v.IP)); c != v.Class {
+			t.Fatal(v.IP, c, "expect", v.Class)
+		}
+	}
+
+}
+
+func TestDNSResolve(t *testing.T) {
+
+	s := newDNSStandIn(t)
+	dns := url.NewDNS(&url.ServerResolver{Server: s.conn.LocalAddr().String()})
+	ctx := context.Background()
+
+	var u url.URL
+	u.Parse("https://www.example.com/path")
+	res, err := dns.Resolve(ctx, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(res.CNAMEs, " ") != "edge.example.net origin.example.org" ||
+		len(res.Addrs) != 2 || res.Addrs[0].IP.String() != "93.184.215.14" ||
+		res.Addrs[1].IP.To4() != nil || res.Addrs[0].Private || res.Addrs[0].Class != url.IPPublic ||
+		res.TTL != 30*time.Second {
+		t.Fatal(res)
+	}
+
+	// cached until the ttl expires
+	n := atomic.LoadInt32(&s.queries)
+	if _, err = dns.Resolve(ctx, &u); err != nil || atomic.LoadInt32(&s.queries) != n {
+		t.Fatal("expected cached answer", err)
+	}
+
+	u.Parse("intranet.example.com")
+	if res, err = dns.Resolve(ctx, &u); err != nil || !res.Addrs[0].Private || res.Addrs[0].Class != url.IPPrivate {
+		t.Fatal("private", res, err)
+	}
+
+	u.Parse("meta.example.com")
+	if res, err = dns.Resolve(ctx, &u); err != nil || res.Addrs[0].Private || res.Addrs[0].Class != url.IPLinkLocal {
+		t.Fatal("link-local", res, err)
+	}
+
+	u.Parse("missing.example.com")
+	if _, err = dns.Resolve(ctx, &u); !errors.Is(err, url.ErrNoAddress) {
+		t.Fatal("nxdomain", err)
+	}
+
+	u.Parse("http://10.0.0.1:8080/")
+	n = atomic.LoadInt32(&s.queries)
+	if res, err = dns.Resolve(ctx, &u); err != nil || res.Addrs[0].Class != url.IPPrivate || atomic.LoadInt32(&s.queries) != n {
+		t.Fatal("ip url", res, err)
+	}
+
+}
+
+func TestDNSZeroValue(t *testing.T) {
+
+	s := newDNSStandIn(t)
+	d := &url.DNS{Resolver: &url.ServerResolver{Server: s.conn.LocalAddr().String()}}
+
+	var u url.URL
+	u.Parse("www.example.com")
+	for i := 0; i < 2; i++ {
+		res, err := d.Resolve(context.Background(), &u)
+		if err != nil || len(res.Addrs) != 2 || atomic.LoadInt32(&s.queries) != 2 {
+			t.Fatal(i, res, err, atomic.LoadInt32(&s.queries))
+		}
+	}
+
+	var zero url.DNS
+	u.Parse("10.0.0.1")
+	if res, err := zero.Resolve(context.Background(), &u); err != nil || res.Addrs[0].Class != url.IPPrivate {
+		t.Fatal(res, err)
+	}
+
+}
+
+func TestNetResolver(t *testing.T) {
+
+	s := newDNSStandIn(t)
+	nr := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.conn.LocalAddr().String())
+		},
+	}
+
+	var u url.URL
+	// a single hop; net.Resolver does not report the chain, which
+	// TestDNSResolve checks against ServerResolver
+	u.Parse("edge.example.net")
+	res, err := url.NewDNS(&url.NetResolver{Resolver: nr}).Resolve(context.Background(), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.CNAMEs) != 1 || res.CNAMEs[0] != "origin.example.org" ||
+		len(res.Addrs) != 2 || res.Addrs[0].IP.String() != "93.184.215.14" || res.TTL != time.Minute {
+		t.Fatal(res)
+	}
+
+}
@@ -0,0 +1,155 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	neturl "net/url"
+	"strings"
+)
+
+/*
+
+	url.Email address parsing that runs the domain through Parse;
+	bare addresses, mailto: urls and "Display Name" <addr> forms
+
+	var e url.Email
+	e.Parse(`"Jane Doe" <Jane.Doe+news@GoogleMail.com>`)
+	e.Name, e.Local, e.Domain.Host // Jane Doe Jane.Doe+news googlemail.com
+	e.Canonical()                  // janedoe@gmail.com
+	url.FPHex64(&e.Domain, url.Apex)
+
+*/
+
+// Email address with the domain parsed as a URL; Local keeps its case
+type Email struct {
+	Name   string // display name
+	Local  string // local part
+	Domain URL
+}
+
+// provider canonicalization rules; dots are ignored in the local part
+// when set, the local part ends at the tag separator and the domain is
+// replaced by the alias
+var emailProviders = map[string]struct {
+	dots  bool
+	tag   byte
+	alias string
+}{
+	"gmail.com":      {true, '+', ""},
+	"googlemail.com": {true, '+', "gmail.com"},
+	"outlook.com":    {false, '+', ""},
+	"hotmail.com":    {false, '+', ""},
+	"live.com":       {false, '+', ""},
+	"icloud.com":     {false, '+', ""},
+	"me.com":         {false, '+', "icloud.com"},
+	"fastmail.com":   {false, '+', ""},
+	"protonmail.com": {false, '+', ""},
+	"proton.me":      {false, '+', ""},
+	"yahoo.com":      {false, '-', ""},
+}
+
+// Parse the address into its parts; the first address of a mailto: url
+// is used and the domain must be a host without a port or path
+func (e *Email) Parse(addr string) bool {
+
+	*e = Email{} // hard reset
+	addr = strings.TrimSpace(addr)
+
+	// mailto:addr,addr?subject=
+	if len(addr) > 7 && strings.EqualFold(addr[:7], "mailto:") {
+		addr = addr[7:]
+		if idx := strings.IndexByte(addr, '?'); idx > -1 {
+			addr = addr[:idx]
+		}
+		if idx := strings.IndexByte(addr, ','); idx > -1 {
+			addr = addr[:idx]
+		}
+		var err error
+		if addr, err = neturl.PathUnescape(addr); err != nil {
+			return false
+		}
+	}
+
+	// "Display Name" <addr>
+	if idx := strings.LastIndexByte(addr, '<'); idx > -1 && strings.HasSuffix(addr, ">") {
+		e.Name = strings.Trim(strings.TrimSpace(addr[:idx]), `"`)
+		addr = strings.TrimSpace(addr[idx+1 : len(addr)-1])
+	}
+
+	idx := strings.LastIndexByte(addr, '@')
+	if idx < 1 || idx == len(addr)-1 || idx > 64 || strings.ContainsAny(addr[:idx], " \t<>") {
+		*e = Email{}
+		return false
+	}
+	e.Local = addr[:idx]
+
+	// domain or address literal; [192.0.2.1] or [IPv6:2001:db8::1]
+	domain := addr[idx+1:]
+	if strings.HasPrefix(domain, "[") && strings.HasSuffix(domain, "]") {
+		domain = domain[1 : len(domain)-1]
+		if len(domain) > 5 && strings.EqualFold(domain[:5], "ipv6:") {
+			domain = domain[5:]
+		}
+	}
+	if strings.ContainsAny(domain, "/?#[]") || !e.Domain.Parse(domain) || len(e.Domain.Port) > 0 {
+		*e = Email{}
+		return false
+	}
+
+	return true
+}
+
+// String returns local@host; address literals are bracketed
+func (e *Email) String() string {
+	if e.Domain.IP {
+		if e.Domain.ipv6 {
+			return e.Local + "@[IPv6:" + e.Domain.Host + "]"
+		}
+		return e.Local + "@[" + e.Domain.Host + "]"
+	}
+	return e.Local + "@" + e.Domain.Host
+}
+
+// Canonical returns the address with the provider rules applied, eg.
+// Gmail ignores dots and +tags; other domains return String
+func (e *Email) Canonical() string {
+
+	p, ok := emailProviders[e.Domain.Host]
+	if !ok {
+		return e.String()
+	}
+
+	local := strings.ToLower(e.Local)
+	if idx := strings.IndexByte(local, p.tag); idx > 0 {
+		local = local[:idx]
+	}
+	if p.dots {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	domain := e.Domain.Host
+	if len(p.alias) > 0 {
+		domain = p.alias
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,74 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestEmail(t *testing.T) {
+
+	for _, v := range []struct {
+		addr, name, local, host, str, canonical string
+	}{
+		{"user@Example.COM", "", "user", "example.com", "user@example.com", "user@example.com"},
+		{`"Jane Doe" <Jane.Doe+news@GoogleMail.com>`, "Jane Doe", "Jane.Doe+news", "googlemail.com", "Jane.Doe+news@googlemail.com", "janedoe@gmail.com"},
+		{"J.O.E+tag@gmail.com", "", "J.O.E+tag", "gmail.com", "J.O.E+tag@gmail.com", "joe@gmail.com"},
+		{"first.last+x@outlook.com", "", "first.last+x", "outlook.com", "first.last+x@outlook.com", "first.last@outlook.com"},
+		{"name-alias@yahoo.com", "", "name-alias", "yahoo.com", "name-alias@yahoo.com", "name@yahoo.com"},
+		{"mailto:info%40x@b%C3%BCcher.example?subject=hi", "", "info@x", "xn--bcher-kva.example", "info@x@xn--bcher-kva.example", "info@x@xn--bcher-kva.example"},
+		{"MAILTO:a@example.org,b@example.org", "", "a", "example.org", "a@example.org", "a@example.org"},
+		{"Ops <ops@[192.0.2.1]>", "Ops", "ops", "192.0.2.1", "ops@[192.0.2.1]", "ops@[192.0.2.1]"},
+		{"ops@[IPv6:2001:db8::1]", "", "ops", "2001:db8::1", "ops@[IPv6:2001:db8::1]", "ops@[IPv6:2001:db8::1]"},
+	} {
+		var e url.Email
+		if !e.Parse(v.addr) {
+			t.Errorf("%q: failed", v.addr)
+			continue
+		}
+		if e.Name != v.name || e.Local != v.local || e.Domain.Host != v.host || e.String() != v.str || e.Canonical() != v.canonical {
+			t.Errorf("%q: %+v %s %s", v.addr, e, e.String(), e.Canonical())
+		}
+	}
+
+	for _, addr := range []string{"", "user", "@example.com", "user@", "user@localhost", "a b@example.com",
+		"user@example.com:25", "user@example.com/path", "mailto:"} {
+		var e url.Email
+		if e.Parse(addr) || e != (url.Email{}) {
+			t.Errorf("%q: %+v", addr, e)
+		}
+	}
+
+	// the domain shares the url fingerprints and apex
+	var e url.Email
+	var u url.URL
+	e.Parse("someone@mail.example.co.uk")
+	u.Parse("www.example.co.uk/path")
+	a, _ := url.FPUint64(&e.Domain, url.Apex)
+	b, _ := url.FPUint64(&u, url.Apex)
+	if apex, _ := url.EffectiveTLDPlusOne(&e.Domain); a != b || apex != "example.co.uk" {
+		t.Error(apex, a, b)
+	}
+}
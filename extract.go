@@ -0,0 +1,214 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+/*
+
+	url.URL extraction from free text; urls with a scheme, www. hosts,
+	bare domains with a public suffix, IPv4 and IPv6 literals and the
+	bracketed [IPv6]:port form, with trailing punctuation removed and
+	parentheses balanced
+
+	for _, m := range url.Extract("see (evil.example.com/x), or 10.1.2.3.") {
+		fmt.Println(m.Start, m.End, m.Text, m.URL.Host)
+	}
+
+*/
+
+// Match is a url found in text; Text is text[Start:End] in bytes
+type Match struct {
+	URL        URL
+	Start, End int
+	Text       string
+}
+
+// Extract returns the urls found in text in order of appearance
+func Extract(text string) (matches []Match) {
+
+	for start := 0; start < len(text); {
+		r, n := utf8.DecodeRuneInString(text[start:])
+		if isDelimiter(r) {
+			start += n
+			continue
+		}
+		end := start + n
+		for end < len(text) {
+			r, n = utf8.DecodeRuneInString(text[end:])
+			if isDelimiter(r) {
+				break
+			}
+			end += n
+		}
+
+		if i, j, ok := candidate(text[start:end]); ok {
+			var m = Match{Start: start + i, End: start + j, Text: text[start+i : start+j]}
+			if m.URL.Parse(m.Text) {
+				matches = append(matches, m)
+			}
+		}
+		start = end
+	}
+
+	return matches
+}
+
+// isDelimiter reports if r ends a candidate token; space, the characters
+// never allowed in a url and non-ascii punctuation such as “” and 。
+func isDelimiter(r rune) bool {
+	switch {
+	case unicode.IsSpace(r), strings.ContainsRune("\"<>{}|\\^`", r):
+		return true
+	case r >= utf8.RuneSelf:
+		return unicode.IsPunct(r) || unicode.IsControl(r)
+	}
+	return r < ' '
+}
+
+// candidate returns the bounds of a url in the token; with a scheme
+// the url starts at the scheme otherwise the host must be an ip or
+// a domain with a public suffix
+func candidate(tok string) (start, end int, ok bool) {
+
+	end = len(tok)
+	var scheme bool
+	if i := strings.Index(tok, "://"); i > -1 {
+		start = i
+		for start > 0 && isSchemeChar(tok[start-1]) {
+			start--
+		}
+		for start < i && !isLetter(tok[start]) {
+			start++
+		}
+		if scheme = start < i; !scheme {
+			start = i + 3
+		}
+	}
+
+	// leading punctuation; keep a bracketed ipv6 literal
+	for !scheme && start < end && strings.IndexByte("([{'*,;:.", tok[start]) > -1 {
+		if tok[start] == '[' {
+			if i := strings.IndexByte(tok[start:end], ']'); i > 0 && net.ParseIP(tok[start+1:start+i]) != nil {
+				break
+			}
+		}
+		start++
+	}
+
+	// trailing punctuation and unbalanced closing brackets
+	for start < end && net.ParseIP(tok[start:end]) == nil {
+		c := tok[end-1]
+		if strings.IndexByte(".,;:!?'*", c) > -1 {
+			end--
+			continue
+		}
+		if i := strings.IndexByte(")]}", c); i > -1 {
+			open := "([{"[i : i+1]
+			if strings.Count(tok[start:end], open) < strings.Count(tok[start:end], string(c)) {
+				end--
+				continue
+			}
+		}
+		break
+	}
+
+	if start == end {
+		return 0, 0, false
+	}
+	if scheme {
+		rest := tok[start:end]
+		rest = rest[strings.Index(rest, "://")+3:]
+		return start, end, validHost(rest, false)
+	}
+	return start, end, validHost(tok[start:end], true)
+}
+
+// validHost reports if the host of the url is an ip literal or a domain
+// name; a url without a scheme must also end in a listed public suffix
+func validHost(s string, bare bool) bool {
+
+	host := s
+	if i := strings.IndexAny(host, "/?#"); i > -1 {
+		host = host[:i]
+	}
+	if i := strings.LastIndexByte(host, '@'); i > -1 {
+		if bare {
+			return false // an email address
+		}
+		host = host[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(host, "["): // [ipv6]:port
+		i := strings.IndexByte(host, ']')
+		return i > 0 && net.ParseIP(host[1:i]) != nil
+	case strings.Count(host, ":") > 1: // bare ipv6
+		return net.ParseIP(host) != nil
+	}
+
+	if i := strings.IndexByte(host, ':'); i > -1 {
+		if _, err := strconv.Atoi(host[i+1:]); err != nil {
+			return false
+		}
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() != nil && strings.Count(host, ".") == 3
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) {
+				return false
+			}
+		}
+	}
+	if !bare {
+		return true
+	}
+
+	ascii, err := puny.ToASCII(strings.ToLower(host))
+	if err != nil {
+		return false
+	}
+	suffix, icann := publicsuffix.PublicSuffix(ascii)
+	return (icann || strings.Contains(suffix, ".")) && len(suffix) < len(ascii)
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+
+func isSchemeChar(c byte) bool {
+	return isLetter(c) || '0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'
+}
@@ -0,0 +1,87 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestExtract(t *testing.T) {
+
+	text := "Visit https://example.com/path?q=1. Also www.test.co.uk, and (evil.example.org/x).\n" +
+		"IPs 192.168.1.1: and [2001:db8::1]:8443/p or 2001:db8::2.\n" +
+		"Unicode bücher.de and “例え.jp”。 wiki https://en.wikipedia.org/wiki/Foo_(bar)) end\n" +
+		"not: file.txt e.g. 3.14 user@mail.com v1.2 localhost ftp:// href=http://a.example.net/'"
+
+	expect := []struct {
+		Text, Host string
+	}{
+		{"https://example.com/path?q=1", "example.com"},
+		{"www.test.co.uk", "www.test.co.uk"},
+		{"evil.example.org/x", "evil.example.org"},
+		{"192.168.1.1", "192.168.1.1"},
+		{"[2001:db8::1]:8443/p", "2001:db8::1"},
+		{"2001:db8::2", "2001:db8::2"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"例え.jp", "xn--r8jz45g.jp"},
+		{"https://en.wikipedia.org/wiki/Foo_(bar)", "en.wikipedia.org"},
+		{"http://a.example.net/", "a.example.net"},
+	}
+
+	matches := url.Extract(text)
+	if len(matches) != len(expect) {
+		for _, m := range matches {
+			t.Log(m.Text)
+		}
+		t.Fatal(len(matches))
+	}
+	for i, m := range matches {
+		if m.Text != expect[i].Text || m.URL.Host != expect[i].Host || text[m.Start:m.End] != m.Text {
+			t.Errorf("%d: %q %q %d:%d", i, m.Text, m.URL.Host, m.Start, m.End)
+		}
+	}
+
+	if m := url.Extract("(see http://a.example.com/x_(y)))"); len(m) != 1 || m[0].Text != "http://a.example.com/x_(y)" || m[0].Start != 5 {
+		t.Fatal(m)
+	}
+	if m := url.Extract(""); len(m) != 0 {
+		t.Fatal(m)
+	}
+
+	// hosts of scheme urls are validated; underscore labels and a
+	// trailing root dot are accepted, email addresses are not
+	for in, host := range map[string]string{
+		"srv http://my_host.example.com/x":                        "my_host.example.com",
+		"dns https://example.com./x":                              "example.com.",
+		"bad http://exa$mple.com/ and hxxp://evil[.]example.com/": "",
+		"mail jane.doe@example.com today":                         "",
+	} {
+		m := url.Extract(in)
+		if host == "" && len(m) != 0 || host != "" && (len(m) != 1 || m[0].URL.Host != strings.TrimSuffix(host, ".")) {
+			t.Errorf("%q: %+v", in, m)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	"github.com/zxdev/xxhash/v2"
+)
+
+/*
+
+	url.URL pluggable fingerprint generator over any hash.Hash
+	sharing the kind based key material of the FP functions
+
+	f := url.NewFingerprinter(fnv.New64a)
+	f.Hex(u, url.Host)
+
+	f = url.NewFingerprinter(func() hash.Hash { return hmac.New(sha1.New, secret) })
+	f.Sum(u, url.Apex)
+
+*/
+
+// preset fingerprinters backing the FP functions
+var (
+	XXH64  = NewFingerprinter(newXXH64)
+	SHA256 = NewFingerprinter(sha256.New)
+)
+
+// Fingerprinter generates kind based keys with a hash constructor;
+// a fresh hash is used per key so it is safe for concurrent use
+type Fingerprinter struct {
+	fn func() hash.Hash
+}
+
+// NewFingerprinter returns a Fingerprinter for the hash constructor,
+// eg. sha1.New, fnv.New64a, or a closure returning a keyed hash
+func NewFingerprinter(fn func() hash.Hash) *Fingerprinter {
+	return &Fingerprinter{fn: fn}
+}
+
+// Sum generates the raw digest key based on the kind request
+func (f *Fingerprinter) Sum(u *URL, kind Kind) (key []byte, err error) {
+	s, err := source(u, kind)
+	if err != nil {
+		return nil, err
+	}
+	return f.sum(s), nil
+}
+
+// Hex generates the hex encoded digest key based on the kind request
+func (f *Fingerprinter) Hex(u *URL, kind Kind) (key string, err error) {
+	b, err := f.Sum(u, kind)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Uint64 generates a key based on the kind request using Sum64 for a
+// hash.Hash64 or else the leading 8 bytes of the digest; big endian
+func (f *Fingerprinter) Uint64(u *URL, kind Kind) (key uint64, err error) {
+	s, err := source(u, kind)
+	if err != nil {
+		return 0, err
+	}
+	return f.uint64(s), nil
+}
+
+// sum returns the digest of s
+func (f *Fingerprinter) sum(s string) []byte {
+	h := f.fn()
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// uint64 returns the 64-bit key of s
+func (f *Fingerprinter) uint64(s string) uint64 {
+
+	h := f.fn()
+	h.Write([]byte(s))
+	if h64, ok := h.(hash.Hash64); ok {
+		return h64.Sum64()
+	}
+
+	b := h.Sum(nil)
+	if len(b) < 8 { // short digest; left pad
+		b = append(make([]byte, 8-len(b)), b...)
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+// xxh64 adapts the xxhash64 string sum to hash.Hash64 by
+// buffering the writes until the sum is requested
+type xxh64 struct{ b []byte }
+
+func newXXH64() hash.Hash { return new(xxh64) }
+
+func (x *xxh64) Write(p []byte) (int, error) { x.b = append(x.b, p...); return len(p), nil }
+func (x *xxh64) Sum64() uint64               { return xxhash.SSum(string(x.b)) }
+func (x *xxh64) Reset()                      { x.b = x.b[:0] }
+func (x *xxh64) Size() int                   { return 8 }
+func (x *xxh64) BlockSize() int              { return 32 }
+func (x *xxh64) Sum(b []byte) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], x.Sum64())
+	return append(b, buf[:]...)
+}
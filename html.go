@@ -0,0 +1,221 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+/*
+
+	url.URL link extraction from html documents; href, src, srcset,
+	action, formaction, poster, background, meta refresh and css url()
+	in style elements and attributes, resolved against the page or the
+	first <base href>; opaque urls such as javascript:, mailto: and data:
+	are typed in Link.Opaque
+
+	links, err := url.Links(r, &page)
+	for _, l := range links {
+		apex, _ := url.EffectiveTLDPlusOne(&l.URL)
+		fmt.Println(l.Element, l.Attribute, l.URL.String(), apex)
+	}
+
+*/
+
+// Link is a url found in an html document; Raw is the value as written
+// and Opaque is set instead of URL for an opaque url, eg. data: or mailto:
+type Link struct {
+	URL       URL
+	Opaque    *Opaque
+	Raw       string
+	Element   string // eg. a, img, meta, style
+	Attribute string // eg. href, srcset, content; empty for a style element
+}
+
+// linkAttrs are the url attributes per element
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"embed":  {"src"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"track":  {"src"},
+	"input":  {"src", "formaction"},
+	"button": {"formaction"},
+	"form":   {"action"},
+	"object": {"data"},
+	"body":   {"background"},
+	"table":  {"background"},
+	"td":     {"background"},
+}
+
+// cssURL matches css url() and @import references
+var cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// Links returns the links of the html document in document order resolved
+// with URL.Resolve; opaque urls are returned with Link.Opaque and links
+// that do not resolve to a url with a host are skipped
+func Links(r io.Reader, page *URL) ([]Link, error) {
+
+	base := *page
+	var hasBase bool
+	var links []Link
+	add := func(element, attribute, raw string) {
+		if IsOpaque(raw) {
+			if o, err := ParseOpaque(raw); err == nil {
+				links = append(links, Link{Opaque: &o, Raw: raw, Element: element, Attribute: attribute})
+			}
+			return
+		}
+		if l, ok := resolveLink(base, raw); ok {
+			links = append(links, Link{URL: l, Raw: raw, Element: element, Attribute: attribute})
+		}
+	}
+
+	z := html.NewTokenizer(r)
+	var inStyle bool
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return links, err
+			}
+			return links, nil
+
+		case html.TextToken:
+			if inStyle {
+				for _, raw := range cssURLs(string(z.Text())) {
+					add("style", "", raw)
+				}
+			}
+
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs[a.Key] = a.Val
+			}
+
+			switch t.Data {
+			case "style":
+				inStyle = true
+			case "base":
+				if href, ok := attrs["href"]; ok && !hasBase {
+					hasBase = true
+					if b, err := base.Resolve(strings.TrimSpace(href)); err == nil {
+						base = b
+					}
+				}
+			case "meta":
+				if strings.EqualFold(attrs["http-equiv"], "refresh") {
+					if raw := refreshURL(attrs["content"]); len(raw) > 0 {
+						add(t.Data, "content", raw)
+					}
+				}
+			}
+
+			for _, attr := range linkAttrs[t.Data] {
+				v, ok := attrs[attr]
+				if !ok {
+					continue
+				}
+				if attr == "srcset" {
+					for _, raw := range srcset(v) {
+						add(t.Data, attr, raw)
+					}
+					continue
+				}
+				add(t.Data, attr, v)
+			}
+			if style, ok := attrs["style"]; ok {
+				for _, raw := range cssURLs(style) {
+					add(t.Data, "style", raw)
+				}
+			}
+		}
+	}
+}
+
+// resolveLink resolves the raw reference against the base; a fragment
+// only reference is the page itself and is skipped
+func resolveLink(base URL, raw string) (URL, bool) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) == 0 || strings.HasPrefix(raw, "#") {
+		return URL{}, false
+	}
+	u, err := base.Resolve(raw)
+	return u, err == nil
+}
+
+// srcset returns the urls of the srcset candidates; "a.png 1x, b.png 2x"
+func srcset(v string) (urls []string) {
+	for _, candidate := range strings.Split(v, ",") {
+		if f := strings.Fields(candidate); len(f) > 0 {
+			urls = append(urls, f[0])
+		}
+	}
+	return urls
+}
+
+// refreshURL returns the url of a meta refresh content; "5; url=/next"
+func refreshURL(content string) string {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return ""
+	}
+	v := strings.TrimSpace(content[i+1:])
+	if len(v) > 4 && strings.EqualFold(v[:3], "url") {
+		if rest := strings.TrimSpace(v[3:]); strings.HasPrefix(rest, "=") {
+			v = strings.TrimSpace(rest[1:])
+		}
+	}
+	return strings.Trim(v, `'"`)
+}
+
+// cssURLs returns the url() and @import references of css text
+func cssURLs(css string) (urls []string) {
+	for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+		for _, v := range m[1:] {
+			if len(v) > 0 {
+				urls = append(urls, v)
+				break
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,119 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestLinks(t *testing.T) {
+
+	doc := `<!doctype html>
+<html><head>
+<meta http-equiv="Refresh" content="5; URL='/next.html'">
+<base href="https://static.example.com/assets/">
+<link rel="stylesheet" href="css/site.css">
+<style>body { background: url("img/bg.png") } @import 'print.css';</style>
+</head>
+<body background="tile.gif">
+<a href="https://www.example.com/about">about</a>
+<a href="//cdn.example.net/x.js">cdn</a>
+<a href="../up/page.html?b=2&amp;a=1#frag">up</a>
+<a href="#top">top</a>
+<a href="javascript:alert(1)">js</a>
+<a href="mailto:a@example.com">mail</a>
+<img src="data:image/gif;base64,R0lGODlh" alt="">
+<img src="logo.png" srcset="logo-1x.png 1x, https://img.example.org/logo-2x.png 2x">
+<form action="/search"><button formaction="submit.cgi">go</button></form>
+<div style="background-image: url(bg2.jpg)"></div>
+</body></html>`
+
+	var page url.URL
+	page.Parse("http://www.example.com/dir/page.html")
+	links, err := url.Links(strings.NewReader(doc), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct{ Element, Attribute, URL string }{
+		{"meta", "content", "www.example.com/next.html"},
+		{"link", "href", "static.example.com/assets/css/site.css"},
+		{"style", "", "static.example.com/assets/img/bg.png"},
+		{"style", "", "static.example.com/assets/print.css"},
+		{"body", "background", "static.example.com/assets/tile.gif"},
+		{"a", "href", "www.example.com/about"},
+		{"a", "href", "cdn.example.net/x.js"},
+		{"a", "href", "static.example.com/up/page.html"},
+		{"a", "href", "javascript:"},
+		{"a", "href", "mailto:"},
+		{"img", "src", "data:"},
+		{"img", "src", "static.example.com/assets/logo.png"},
+		{"img", "srcset", "static.example.com/assets/logo-1x.png"},
+		{"img", "srcset", "img.example.org/logo-2x.png"},
+		{"form", "action", "static.example.com/search"},
+		{"button", "formaction", "static.example.com/assets/submit.cgi"},
+		{"div", "style", "static.example.com/assets/bg2.jpg"},
+	}
+	if len(links) != len(expect) {
+		for _, l := range links {
+			t.Log(l.Element, l.Attribute, l.URL.String())
+		}
+		t.Fatal(len(links))
+	}
+	for i, l := range links {
+		s := l.URL.String()
+		if l.Opaque != nil {
+			s = l.Opaque.Scheme + ":"
+		}
+		if l.Element != expect[i].Element || l.Attribute != expect[i].Attribute || s != expect[i].URL {
+			t.Errorf("%d: %s %s %s", i, l.Element, l.Attribute, l.URL.String())
+		}
+	}
+	if e, ok := links[9].Opaque.Email(); !ok || e.Domain.Host != "example.com" {
+		t.Error(links[9].Opaque)
+	}
+	if o := links[10].Opaque; o.MediaType != "image/gif" || string(o.Data) != "GIF89a" {
+		t.Error(o)
+	}
+	if links[7].URL.Query != "a=1&b=2" || links[7].Raw != "../up/page.html?b=2&a=1#frag" {
+		t.Error(links[7].URL.Query, links[7].Raw)
+	}
+
+	// split external links from the page apex
+	apex, _ := url.EffectiveTLDPlusOne(&page)
+	var external int
+	for i := range links {
+		if links[i].Opaque != nil {
+			continue
+		}
+		if a, _ := url.EffectiveTLDPlusOne(&links[i].URL); a != apex {
+			external++
+		}
+	}
+	if external != 2 {
+		t.Error(external)
+	}
+}
@@ -0,0 +1,269 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+
+	url.URL keyed fingerprints for privacy preserving logs
+
+	the secret salts the digest so keys can not be reversed with a
+	dictionary of hosts, and the key id travels with each key as
+	"id.digest" so logs join within a rotation period
+
+	k, err := url.NewKeyring(1, secret) // secret of 16 bytes or more
+	k.HMAC(u, url.Host)   // 1.5f0c...
+	k.Rotate(2, next)     // new keys use id 2
+	k.Match(u, url.Host, "1.5f0c...") // true until id 1 is retired
+
+*/
+
+// MinSecret is the shortest secret a Keyring accepts
+const MinSecret = 16
+
+// ErrSecret reports a secret shorter than MinSecret or a Keyring
+// without an active secret
+var ErrSecret = errors.New("url: keyring secret missing or too short")
+
+// Keyring holds the secrets for keyed fingerprints; new keys are
+// generated with the active secret while older ones remain
+// available to Match until retired; the zero value has no active
+// secret until Rotate
+type Keyring struct {
+	mu     sync.RWMutex
+	active uint32
+	keys   map[uint32]*keyed
+}
+
+// keyed is the per secret fingerprinter pair
+type keyed struct {
+	hmac, sip *Fingerprinter
+}
+
+// NewKeyring returns a Keyring with the secret active under id
+func NewKeyring(id uint32, secret []byte) (*Keyring, error) {
+	k := new(Keyring)
+	if err := k.Rotate(id, secret); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate installs the secret under id and makes it the active key;
+// secrets shorter than MinSecret are rejected with ErrSecret
+func (k *Keyring) Rotate(id uint32, secret []byte) error {
+
+	if len(secret) < MinSecret {
+		return ErrSecret
+	}
+	secret = append([]byte(nil), secret...)
+
+	// siphash takes a 128-bit key; use a 16 byte secret as is
+	// otherwise derive one from the secret with hmac-sha256
+	var sk [16]byte
+	if len(secret) == len(sk) {
+		copy(sk[:], secret)
+	} else {
+		m := hmac.New(sha256.New, secret)
+		m.Write([]byte("siphash"))
+		copy(sk[:], m.Sum(nil))
+	}
+
+	k.mu.Lock()
+	if k.keys == nil {
+		k.keys = make(map[uint32]*keyed)
+	}
+	k.keys[id] = &keyed{
+		hmac: NewFingerprinter(func() hash.Hash { return hmac.New(sha256.New, secret) }),
+		sip:  NewFingerprinter(func() hash.Hash { return NewSipHash(sk) }),
+	}
+	k.active = id
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Retire removes the secret under id; the active key is never removed
+func (k *Keyring) Retire(id uint32) {
+	k.mu.Lock()
+	if id != k.active {
+		delete(k.keys, id)
+	}
+	k.mu.Unlock()
+}
+
+// Active reports the id of the key used for new fingerprints
+func (k *Keyring) Active() uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// get returns the fingerprinters for id
+func (k *Keyring) get(id uint32) (*keyed, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	f, ok := k.keys[id]
+	return f, ok
+}
+
+// current returns the active id and fingerprinters
+func (k *Keyring) current() (uint32, *keyed) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active, k.keys[k.active]
+}
+
+// HMAC generates an "id.hex" hmac-sha256 key based on the kind request
+func (k *Keyring) HMAC(u *URL, kind Kind) (key string, err error) {
+	id, f := k.current()
+	if f == nil {
+		return "", ErrSecret
+	}
+	b, err := f.hmac.Sum(u, kind)
+	if err != nil {
+		return "", err
+	}
+	return keyString(id, b), nil
+}
+
+// HMACByte generates an hmac-sha256 key based on the kind request
+// prefixed with the 4 byte big endian key id
+func (k *Keyring) HMACByte(u *URL, kind Kind) (key []byte, err error) {
+	id, f := k.current()
+	if f == nil {
+		return nil, ErrSecret
+	}
+	b, err := f.hmac.Sum(u, kind)
+	if err != nil {
+		return nil, err
+	}
+	return keyBytes(id, b), nil
+}
+
+// SipHash generates an "id.hex" keyed siphash-2-4 key based on the kind request
+func (k *Keyring) SipHash(u *URL, kind Kind) (key string, err error) {
+	id, f := k.current()
+	if f == nil {
+		return "", ErrSecret
+	}
+	b, err := f.sip.Sum(u, kind)
+	if err != nil {
+		return "", err
+	}
+	return keyString(id, b), nil
+}
+
+// SipHashByte generates a keyed siphash-2-4 key based on the kind
+// request prefixed with the 4 byte big endian key id
+func (k *Keyring) SipHashByte(u *URL, kind Kind) (key []byte, err error) {
+	id, f := k.current()
+	if f == nil {
+		return nil, ErrSecret
+	}
+	b, err := f.sip.Sum(u, kind)
+	if err != nil {
+		return nil, err
+	}
+	return keyBytes(id, b), nil
+}
+
+// Match reports if key, in either the string or byte form, was
+// generated from u and kind with a secret still on the keyring
+func (k *Keyring) Match(u *URL, kind Kind, key interface{}) bool {
+
+	id, digest, ok := splitKey(key)
+	if !ok {
+		return false
+	}
+	f, ok := k.get(id)
+	if !ok {
+		return false
+	}
+
+	var b []byte
+	var err error
+	switch len(digest) {
+	case sha256.Size:
+		b, err = f.hmac.Sum(u, kind)
+	case 8:
+		b, err = f.sip.Sum(u, kind)
+	default:
+		return false
+	}
+
+	return err == nil && hmac.Equal(b, digest)
+}
+
+// KeyID extracts the key id from a keyed fingerprint; string or byte form
+func KeyID(key interface{}) (id uint32, ok bool) {
+	id, _, ok = splitKey(key)
+	return
+}
+
+// splitKey separates a keyed fingerprint into the key id and digest
+func splitKey(key interface{}) (id uint32, digest []byte, ok bool) {
+
+	switch v := key.(type) {
+	case string:
+		i := strings.IndexByte(v, '.')
+		if i < 1 {
+			return
+		}
+		n, err := strconv.ParseUint(v[:i], 10, 32)
+		if err != nil {
+			return
+		}
+		if digest, err = hex.DecodeString(v[i+1:]); err != nil {
+			return
+		}
+		return uint32(n), digest, len(digest) > 0
+	case []byte:
+		if len(v) > 4 {
+			return binary.BigEndian.Uint32(v), v[4:], true
+		}
+	}
+
+	return
+}
+
+func keyString(id uint32, b []byte) string {
+	return strconv.FormatUint(uint64(id), 10) + "." + hex.EncodeToString(b)
+}
+
+func keyBytes(id uint32, b []byte) []byte {
+	key := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(key, id)
+	return append(key, b...)
+}
@@ -0,0 +1,123 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestSipHash(t *testing.T) {
+
+	// reference vectors; key 00..0f and message 00..len-1
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for n, want := range map[int]uint64{
+		0:  0x726fdb47dd0e0e31,
+		7:  0xab0200f58b01d137,
+		8:  0x93f5f5799a932462,
+		15: 0xa129ca6149be45e5,
+	} {
+		h := url.NewSipHash(key)
+		for i := 0; i < n; i++ {
+			h.Write([]byte{byte(i)})
+		}
+		if sum := h.Sum64(); sum != want {
+			t.Fatalf("len %d: %016x != %016x", n, sum, want)
+		}
+	}
+
+}
+
+func TestKeyring(t *testing.T) {
+
+	var u url.URL
+	u.Parse("www.example.com/path/logo.jpg")
+
+	k, err := url.NewKeyring(1, []byte("first secret, 24 bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, err := k.HMAC(&u, url.Host)
+	if err != nil || !strings.HasPrefix(h1, "1.") || len(h1) != 2+64 {
+		t.Fatal("hmac", h1, err)
+	}
+	if plain, _ := url.FPHex256(&u, url.Host); strings.HasSuffix(h1, plain) {
+		t.Fatal("hmac matches unkeyed sha256")
+	}
+	s1, _ := k.SipHash(&u, url.Host)
+	b1, _ := k.HMACByte(&u, url.Apex)
+
+	if err := k.Rotate(2, []byte("0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	h2, _ := k.HMAC(&u, url.Host)
+	if id, _ := url.KeyID(h2); id != 2 || h1[2:] == h2[2:] {
+		t.Fatal("rotation", h1, h2)
+	}
+
+	for _, key := range []interface{}{h1, s1, h2} {
+		if !k.Match(&u, url.Host, key) {
+			t.Fatal("expected match", key)
+		}
+	}
+	if !k.Match(&u, url.Apex, b1) || k.Match(&u, url.Host, b1) {
+		t.Fatal("byte form match")
+	}
+
+	k.Retire(1)
+	k.Retire(2) // active; ignored
+	if k.Match(&u, url.Host, h1) || !k.Match(&u, url.Host, h2) {
+		t.Fatal("retired key still matches")
+	}
+
+	u.Parse("www.example.org/path/logo.jpg")
+	if k.Match(&u, url.Host, h2) {
+		t.Fatal("match on different host")
+	}
+
+	// short secrets are rejected and the zero value needs a Rotate
+	for _, secret := range [][]byte{nil, []byte("short")} {
+		if _, err := url.NewKeyring(3, secret); err != url.ErrSecret {
+			t.Fatal("expected short secret", err)
+		}
+		if err := k.Rotate(3, secret); err != url.ErrSecret || k.Active() != 2 {
+			t.Fatal("expected short secret", err, k.Active())
+		}
+	}
+	var zero url.Keyring
+	if _, err := zero.HMAC(&u, url.Host); err != url.ErrSecret || zero.Match(&u, url.Host, h2) {
+		t.Fatal("zero keyring", err)
+	}
+	if err := zero.Rotate(1, []byte("0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := zero.SipHash(&u, url.Host); err != nil || !zero.Match(&u, url.Host, key) {
+		t.Fatal("zero keyring rotate", key, err)
+	}
+
+}
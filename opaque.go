@@ -0,0 +1,147 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+)
+
+/*
+
+	url.Opaque non-hierarchical scheme recognition; Parse rejects these
+	and ParseOpaque returns the typed form, decoding data: uris
+
+	o, err := url.ParseOpaque("data:text/html;base64,PGI+aGk8L2I+")
+	o.Scheme, o.MediaType, string(o.Data) // data text/html <b>hi</b>
+
+	o, _ = url.ParseOpaque("mailto:ops@example.com?subject=x")
+	e, ok := o.Email()
+
+*/
+
+// ErrOpaque reports a string that is not an opaque url or does not decode
+var ErrOpaque = errors.New("url: invalid opaque url")
+
+// opaqueSchemes are the recognized non-hierarchical schemes
+var opaqueSchemes = map[string]bool{
+	"about": true, "bitcoin": true, "blob": true, "callto": true, "data": true,
+	"facetime": true, "geo": true, "javascript": true, "magnet": true, "mailto": true,
+	"news": true, "sip": true, "sips": true, "skype": true, "sms": true,
+	"tel": true, "urn": true, "vbscript": true, "xmpp": true,
+}
+
+// Opaque is a url with a non-hierarchical scheme; MediaType, Base64 and
+// Data are set for a data: uri
+type Opaque struct {
+	Scheme    string // lowercase
+	Opaque    string // after the colon; the fragment is removed
+	MediaType string // data: media type and parameters
+	Base64    bool
+	Data      []byte
+}
+
+// IsOpaque reports if the url starts with a recognized opaque scheme
+func IsOpaque(url string) bool {
+	_, ok := opaqueScheme(url)
+	return ok
+}
+
+// opaqueScheme returns the lowercase scheme of an opaque url; the rest
+// must not be an authority, eg. blob:https://x is opaque but sip://x is not
+func opaqueScheme(url string) (string, bool) {
+	idx := strings.IndexByte(url, ':')
+	if idx < 1 || idx > 10 {
+		return "", false
+	}
+	scheme := strings.ToLower(strings.TrimSpace(url[:idx]))
+	return scheme, opaqueSchemes[scheme] && !strings.HasPrefix(url[idx+1:], "//")
+}
+
+// ParseOpaque parses an opaque url; a data: uri payload is decoded with
+// the media type defaulting to text/plain;charset=US-ASCII
+func ParseOpaque(url string) (o Opaque, err error) {
+
+	url = strings.TrimSpace(url)
+	scheme, ok := opaqueScheme(url)
+	if !ok {
+		return o, fmt.Errorf("%w: %.32q", ErrOpaque, url)
+	}
+	o.Scheme = scheme
+	o.Opaque = url[strings.IndexByte(url, ':')+1:]
+	if idx := strings.IndexByte(o.Opaque, '#'); idx > -1 && scheme != "data" {
+		o.Opaque = o.Opaque[:idx]
+	}
+	if scheme != "data" {
+		return o, nil
+	}
+
+	// data:[<mediatype>][;base64],<data>
+	idx := strings.IndexByte(o.Opaque, ',')
+	if idx < 0 {
+		return o, fmt.Errorf("%w: data: without a comma", ErrOpaque)
+	}
+	payload := o.Opaque[idx+1:]
+	o.MediaType = strings.TrimSpace(o.Opaque[:idx])
+	if strings.HasSuffix(strings.ToLower(o.MediaType), ";base64") {
+		o.MediaType, o.Base64 = o.MediaType[:len(o.MediaType)-7], true
+	}
+	switch {
+	case len(o.MediaType) == 0:
+		o.MediaType = "text/plain;charset=US-ASCII"
+	case strings.HasPrefix(o.MediaType, ";"):
+		o.MediaType = "text/plain" + o.MediaType
+	}
+
+	if payload, err = neturl.PathUnescape(payload); err != nil {
+		return o, fmt.Errorf("%w: %v", ErrOpaque, err)
+	}
+	if !o.Base64 {
+		o.Data = []byte(payload)
+		return o, nil
+	}
+
+	payload = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, payload)
+	if o.Data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "=")); err != nil {
+		return o, fmt.Errorf("%w: %v", ErrOpaque, err)
+	}
+
+	return o, nil
+}
+
+// Email returns the first address of a mailto: url
+func (o *Opaque) Email() (e Email, ok bool) {
+	if o.Scheme != "mailto" {
+		return e, false
+	}
+	ok = e.Parse("mailto:" + o.Opaque)
+	return e, ok
+}
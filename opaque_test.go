@@ -0,0 +1,79 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestOpaque(t *testing.T) {
+
+	for _, v := range []struct {
+		in, scheme, opaque, media string
+		base64                    bool
+		data                      string
+	}{
+		{"data:text/html;base64,PGI+aGk8L2I+", "data", "text/html;base64,PGI+aGk8L2I+", "text/html", true, "<b>hi</b>"},
+		{"DATA:,Hello%2C%20World!", "data", ",Hello%2C%20World!", "text/plain;charset=US-ASCII", false, "Hello, World!"},
+		{"data:;charset=utf-8;BASE64,aGk", "data", ";charset=utf-8;BASE64,aGk", "text/plain;charset=utf-8", true, "hi"},
+		{"data:image/png;base64,iVBO\nRw0K", "data", "image/png;base64,iVBO\nRw0K", "image/png", true, "\x89PNG\r\n"},
+		{"javascript:alert(1)#x", "javascript", "alert(1)", "", false, ""},
+		{"tel:+1-555-0100", "tel", "+1-555-0100", "", false, ""},
+		{"blob:https://example.com/uuid", "blob", "https://example.com/uuid", "", false, ""},
+		{"urn:isbn:0451450523", "urn", "isbn:0451450523", "", false, ""},
+	} {
+		o, err := url.ParseOpaque(v.in)
+		if err != nil || o.Scheme != v.scheme || o.Opaque != v.opaque || o.MediaType != v.media ||
+			o.Base64 != v.base64 || string(o.Data) != v.data {
+			t.Errorf("%q: %+v %v", v.in, o, err)
+		}
+		var u url.URL
+		if u.Parse(v.in) || !url.IsOpaque(v.in) {
+			t.Errorf("%q: parsed as %+v", v.in, u)
+		}
+	}
+
+	for _, in := range []string{"data:text/plain", "data:;base64,!!!", "http://example.com", "example.com:8080", "sip://host"} {
+		if _, err := url.ParseOpaque(in); !errors.Is(err, url.ErrOpaque) {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+
+	o, _ := url.ParseOpaque("mailto:ops@Example.com?subject=x")
+	if e, ok := o.Email(); !ok || e.String() != "ops@example.com" {
+		t.Error(e, ok)
+	}
+	o, _ = url.ParseOpaque("tel:+1")
+	if _, ok := o.Email(); ok {
+		t.Error("tel email")
+	}
+
+	// hosts with ports are not mistaken for schemes
+	var u url.URL
+	if !u.Parse("example.com:8080/x") || u.Port != "8080" {
+		t.Error(u)
+	}
+}
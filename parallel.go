@@ -0,0 +1,237 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+/*
+
+	url.URL parallel batch parser; lines are fanned out in batches to
+	workers running Parse and the optional fingerprint kinds
+
+	p := url.NewParallelParser(r)
+	p.Workers, p.Ordered = 8, true
+	p.Kinds = []url.Kind{url.Apex, url.Host}
+	for batch := range p.Run(ctx) {
+		for _, r := range batch {
+			if r.Err == nil {
+				fmt.Println(r.Line, r.URL.Host, r.Keys[0])
+			}
+		}
+	}
+	err := p.Err()
+
+*/
+
+// DefaultBatchSize is the number of lines per ParallelParser batch
+const DefaultBatchSize = 256
+
+// Result is a parsed line; Err is a *ParseError for an invalid or long line
+// and Keys holds the Uint64 key per kind, 0 for a kind without key material
+type Result struct {
+	Line int
+	Text string
+	URL  URL
+	Keys []uint64
+	Err  error
+}
+
+// ParallelParser parses the lines of an io.Reader with a pool of workers;
+// the options must be set before Run and the batches must be drained or
+// the context cancelled to release the workers
+type ParallelParser struct {
+	Workers       int            // default runtime.NumCPU()
+	BatchSize     int            // default DefaultBatchSize
+	MaxLine       int            // default DefaultMaxLine
+	Ordered       bool           // deliver batches in input order
+	Kinds         []Kind         // fingerprint kinds for Result.Keys
+	Fingerprinter *Fingerprinter // default XXH64 as FPUint64
+
+	r   io.Reader
+	ctx context.Context
+	err error
+}
+
+// NewParallelParser returns a ParallelParser reading from r
+func NewParallelParser(r io.Reader) *ParallelParser {
+	return &ParallelParser{r: r}
+}
+
+// Run starts the pipeline and returns the batches of results; the channel
+// is closed at the end of input, on a read error or when ctx is done
+func (p *ParallelParser) Run(ctx context.Context) <-chan []Result {
+
+	p.ctx = ctx
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	size := p.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	fp := p.Fingerprinter
+	if fp == nil {
+		fp = XXH64
+	}
+
+	type job struct {
+		seq   int
+		batch []Result
+	}
+	jobs := make(chan job, workers)
+	done := make(chan job, workers)
+	out := make(chan []Result, workers)
+
+	// reader; splits the lines into numbered batches
+	go func() {
+		defer close(jobs)
+
+		s := NewScanner(p.r)
+		s.MaxLine = p.MaxLine
+		var seq int
+		batch := make([]Result, 0, size)
+		send := func() bool {
+			select {
+			case jobs <- job{seq, batch}:
+				seq++
+				batch = make([]Result, 0, size)
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			text, long, ok := s.read()
+			if !ok {
+				break
+			}
+			r := Result{Line: s.line, Text: text}
+			switch {
+			case long:
+				r.Err = &ParseError{Line: s.line, Err: ErrLineTooLong}
+			case len(strings.TrimSpace(text)) == 0:
+				continue
+			}
+			if batch = append(batch, r); len(batch) == size && !send() {
+				return
+			}
+		}
+		if len(batch) > 0 && !send() {
+			return
+		}
+		p.err = s.err
+	}()
+
+	// workers; parse and fingerprint each batch in place
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				p.parse(j.batch, fp)
+				done <- j
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// collector; drains after cancellation so out closes only once the
+	// reader and workers have stopped
+	go func() {
+		defer close(out)
+
+		next := 0
+		pending := make(map[int][]Result)
+		for j := range done {
+			if ctx.Err() != nil {
+				continue
+			}
+			if !p.Ordered {
+				select {
+				case out <- j.batch:
+				case <-ctx.Done():
+				}
+				continue
+			}
+			pending[j.seq] = j.batch
+			for batch, ok := pending[next]; ok; batch, ok = pending[next] {
+				delete(pending, next)
+				next++
+				select {
+				case out <- batch:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// Err returns the read error or the context error once the batches
+// channel is closed; nil at the end of input
+func (p *ParallelParser) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.ctx != nil {
+		return p.ctx.Err()
+	}
+	return nil
+}
+
+// parse parses the batch and generates the keys; the keys of a
+// batch share one allocation
+func (p *ParallelParser) parse(batch []Result, fp *Fingerprinter) {
+
+	n := len(p.Kinds)
+	keys := make([]uint64, len(batch)*n)
+	for i := range batch {
+		r := &batch[i]
+		if r.Err != nil {
+			continue
+		}
+		if !r.URL.Parse(r.Text) {
+			r.Err = &ParseError{Line: r.Line, Text: r.Text, Err: ErrInvalid}
+			continue
+		}
+		if n > 0 {
+			r.Keys = keys[i*n : (i+1)*n : (i+1)*n]
+			for k, kind := range p.Kinds {
+				r.Keys[k], _ = fp.Uint64(&r.URL, kind)
+			}
+		}
+	}
+}
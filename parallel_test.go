@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestParallelParser(t *testing.T) {
+
+	var b strings.Builder
+	for i := 1; i <= 5000; i++ {
+		switch {
+		case i%100 == 0:
+			b.WriteString("invalid\n")
+		case i%250 == 1:
+			b.WriteString("\n")
+		default:
+			fmt.Fprintf(&b, "h%d.example%d.com/p/%d.html\n", i, i%7, i)
+		}
+	}
+	input := b.String()
+
+	for _, ordered := range []bool{true, false} {
+		p := url.NewParallelParser(strings.NewReader(input))
+		p.Workers, p.BatchSize, p.Ordered = 4, 7, ordered
+		p.Kinds = []url.Kind{url.Apex, url.Host}
+
+		seen := make(map[int]bool)
+		var last, valid, invalid int
+		for batch := range p.Run(context.Background()) {
+			for _, r := range batch {
+				if ordered && r.Line <= last {
+					t.Fatal("out of order", r.Line, last)
+				}
+				last = r.Line
+				seen[r.Line] = true
+				if r.Err != nil {
+					invalid++
+					if r.Text != "invalid" || r.Line%100 != 0 {
+						t.Fatal(r.Line, r.Err)
+					}
+					continue
+				}
+				valid++
+				apex, _ := url.FPUint64(&r.URL, url.Apex)
+				host, _ := url.FPUint64(&r.URL, url.Host)
+				if len(r.Keys) != 2 || r.Keys[0] != apex || r.Keys[1] != host ||
+					r.URL.Host != fmt.Sprintf("h%d.example%d.com", r.Line, r.Line%7) {
+					t.Fatal(r.Line, r.URL, r.Keys)
+				}
+			}
+		}
+		if p.Err() != nil || len(seen) != 4980 || valid != 4930 || invalid != 50 {
+			t.Fatal(ordered, p.Err(), len(seen), valid, invalid)
+		}
+	}
+
+	// cancellation closes the channel and reports the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := url.NewParallelParser(strings.NewReader(strings.Repeat(input, 10)))
+	p.BatchSize = 16
+	var batches int
+	for range p.Run(ctx) {
+		if batches++; batches == 2 {
+			cancel()
+		}
+	}
+	if p.Err() != context.Canceled || batches > 1000 {
+		t.Fatal(p.Err(), batches)
+	}
+}
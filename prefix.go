@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import "strings"
+
+/*
+
+	url.URL hierarchical prefix expressions for prefix blocking
+
+	u.Parse("a.b.example.com/1/2/page.html")
+	url.Prefixes(u)
+	a.b.example.com/
+	a.b.example.com/1/
+	a.b.example.com/1/2/
+	a.b.example.com/1/2/page.html
+	b.example.com/
+	b.example.com/1/
+	b.example.com/1/2/
+	b.example.com/1/2/page.html
+	example.com/
+	example.com/1/
+	example.com/1/2/
+	example.com/1/2/page.html
+
+	the path prefixes match the PathPrefix(n) kind keys
+
+*/
+
+// Prefixes returns the expressions for the host and each parent host
+// down to the apex, crossed with the root, every ancestor directory and
+// the full path; hosts run most specific first and paths root first.
+// The port is not part of an expression and an IP has no parent hosts
+func Prefixes(u *URL) []string {
+
+	if len(u.Host) == 0 {
+		return nil
+	}
+
+	hosts := []string{u.Host}
+	if apex, err := EffectiveTLDPlusOne(u); err == nil && !u.IP {
+		for host := u.Host; len(host) > len(apex); {
+			host = host[strings.IndexByte(host, '.')+1:]
+			hosts = append(hosts, host)
+		}
+	}
+
+	paths := directories(u)
+	if path, _ := source(u, PathOnly); path != paths[len(paths)-1] {
+		paths = append(paths, path)
+	}
+
+	prefixes := make([]string, 0, len(hosts)*len(paths))
+	for _, host := range hosts {
+		for _, path := range paths {
+			prefixes = append(prefixes, host+path)
+		}
+	}
+
+	return prefixes
+}
+
+// Prefixes returns the digest of each Prefixes expression in order
+func (f *Fingerprinter) Prefixes(u *URL) [][]byte {
+	prefixes := Prefixes(u)
+	keys := make([][]byte, len(prefixes))
+	for i := range prefixes {
+		keys[i] = f.sum(prefixes[i])
+	}
+	return keys
+}
+
+// PrefixesUint64 returns the 64-bit key of each Prefixes expression in order
+func (f *Fingerprinter) PrefixesUint64(u *URL) []uint64 {
+	prefixes := Prefixes(u)
+	keys := make([]uint64, len(prefixes))
+	for i := range prefixes {
+		keys[i] = f.uint64(prefixes[i])
+	}
+	return keys
+}
+
+// FPPrefixUint64 is a xxhash64 prefix key generator utility
+func FPPrefixUint64(u *URL) []uint64 { return XXH64.PrefixesUint64(u) }
+
+// FPPrefixByte256 is a sha256 prefix key generator utility
+func FPPrefixByte256(u *URL) [][]byte { return SHA256.Prefixes(u) }
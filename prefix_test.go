@@ -0,0 +1,77 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestPrefixes(t *testing.T) {
+
+	var u url.URL
+	for _, v := range []struct {
+		In  string
+		Out []string
+	}{
+		{"example.com", []string{"example.com/"}},
+		{"http://a.b.example.co.uk:8080/1/2/c.html?q=1", []string{
+			"a.b.example.co.uk/", "a.b.example.co.uk/1/", "a.b.example.co.uk/1/2/", "a.b.example.co.uk/1/2/c.html",
+			"b.example.co.uk/", "b.example.co.uk/1/", "b.example.co.uk/1/2/", "b.example.co.uk/1/2/c.html",
+			"example.co.uk/", "example.co.uk/1/", "example.co.uk/1/2/", "example.co.uk/1/2/c.html",
+		}},
+		{"www.example.com/path/page/", []string{
+			"www.example.com/", "www.example.com/path/", "www.example.com/path/page/",
+			"example.com/", "example.com/path/", "example.com/path/page/",
+		}},
+		{"www.example.com/logo.jpg", []string{
+			"www.example.com/", "www.example.com/logo.jpg",
+			"example.com/", "example.com/logo.jpg",
+		}},
+		{"10.10.10.10/a/b", []string{"10.10.10.10/", "10.10.10.10/a/", "10.10.10.10/a/b"}},
+		{"bad", nil},
+	} {
+		u.Parse(v.In)
+		if out := url.Prefixes(&u); !reflect.DeepEqual(out, v.Out) {
+			t.Fatal(v.In, out)
+		}
+	}
+
+	u.Parse("www.example.com/path/level/logo.jpg")
+	keys, sums := url.FPPrefixUint64(&u), url.FPPrefixByte256(&u)
+	if len(keys) != 8 || len(sums) != 8 {
+		t.Fatal("expected 8 keys", len(keys), len(sums))
+	}
+	if key, _ := url.FPUint64(&u, url.PathPrefix(1)); keys[1] != key {
+		t.Fatal("path prefix kind mismatch")
+	}
+	sum := sha256.Sum256([]byte("example.com/path/level/logo.jpg"))
+	if !bytes.Equal(sums[7], sum[:]) {
+		t.Fatal("sha256 mismatch")
+	}
+
+}
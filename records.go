@@ -0,0 +1,255 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+
+	url.URL structured record scanners for CSV and TSV with a header row
+	and JSON Lines; the url is taken from the named column or dotted JSON
+	path and the rest of the record is returned alongside
+
+	s := url.NewCSVScanner(r, "url")
+	for s.Scan() {
+		rec := s.Record()
+		fmt.Println(rec.Number, rec.URL.Host, rec.Fields)
+	}
+
+	s = url.NewJSONLScanner(r, "request.url")
+	for s.Scan() {
+		rec := s.Record()
+		fmt.Println(rec.Line, rec.URL.Host, rec.Object["ts"])
+	}
+
+*/
+
+// ErrField reports a missing column or JSON path, or a non-string value
+var ErrField = errors.New("url: field not found")
+
+// Record is a parsed record; Fields holds the CSV|TSV fields and Object
+// the decoded JSON object, numbers as json.Number
+type Record struct {
+	Line   int // line the record starts on
+	Number int // 1-based record number; the header is not counted
+	URL    URL
+	Fields []string
+	Object map[string]interface{}
+}
+
+// RecordScanner reads structured records; records that do not decode or
+// hold an invalid url are skipped and counted unless StopOnError is set,
+// reported as a *ParseError with the line and record number. MaxLine and
+// StopOnError must be set before the first Scan
+type RecordScanner struct {
+	MaxLine     int  // longest JSON Lines line accepted; default DefaultMaxLine
+	StopOnError bool // stop at the first invalid record; default skip
+	Stats       Stats
+
+	next func(rec *Record) (text string, err error)
+	rec  Record
+	err  error
+}
+
+// NewCSVScanner returns a RecordScanner over comma separated values
+// with a header row naming the url column; case insensitive
+func NewCSVScanner(r io.Reader, column string) *RecordScanner {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return newDelimited(cr, column)
+}
+
+// NewTSVScanner returns a RecordScanner over tab separated values
+// with a header row naming the url column; case insensitive
+func NewTSVScanner(r io.Reader, column string) *RecordScanner {
+	cr := csv.NewReader(r)
+	cr.Comma = '\t'
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+	return newDelimited(cr, column)
+}
+
+// newDelimited reads the url column from a csv.Reader; the header is
+// read by the first Scan
+func newDelimited(cr *csv.Reader, column string) *RecordScanner {
+
+	index := -1
+	var number int
+	return &RecordScanner{next: func(rec *Record) (string, error) {
+
+		if index < 0 {
+			header, err := cr.Read()
+			if err != nil {
+				return "", err // io.EOF; an empty stream has no records
+			}
+			for i := range header {
+				if strings.EqualFold(strings.TrimSpace(header[i]), column) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return "", fmt.Errorf("%w: column %q", ErrField, column)
+			}
+		}
+
+		fields, err := cr.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return "", err
+			}
+			number++
+			*rec = Record{Line: perr.StartLine, Number: number}
+			return "", &ParseError{Line: perr.StartLine, Record: number, Err: perr.Err}
+		}
+
+		number++
+		line, _ := cr.FieldPos(0)
+		*rec = Record{Line: line, Number: number, Fields: fields}
+		if index >= len(fields) {
+			return "", &ParseError{Line: line, Record: number, Err: ErrField}
+		}
+		return fields[index], nil
+	}}
+}
+
+// NewJSONLScanner returns a RecordScanner over JSON Lines objects taking
+// the url from the dotted path, eg. "url" or "request.url"; blank lines
+// are skipped and lines over MaxLine are reported as too long
+func NewJSONLScanner(r io.Reader, path string) *RecordScanner {
+
+	s := NewScanner(r)
+	keys := strings.Split(path, ".")
+	var number int
+	rs := new(RecordScanner)
+	rs.next = func(rec *Record) (string, error) {
+
+		if s.sc == nil { // first read
+			s.MaxLine = rs.MaxLine
+		}
+
+		var text string
+		for {
+			var long, ok bool
+			if text, long, ok = s.read(); !ok {
+				if s.err != nil {
+					return "", s.err
+				}
+				return "", io.EOF
+			}
+			if long || len(strings.TrimSpace(text)) > 0 {
+				number++
+				*rec = Record{Line: s.line, Number: number}
+				if long {
+					return "", &ParseError{Line: s.line, Record: number, Err: ErrLineTooLong}
+				}
+				break
+			}
+		}
+
+		d := json.NewDecoder(strings.NewReader(text))
+		d.UseNumber()
+		if err := d.Decode(&rec.Object); err != nil {
+			return "", &ParseError{Line: s.line, Record: number, Text: text, Err: err}
+		}
+
+		var v interface{} = rec.Object
+		for _, key := range keys {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = obj[key]
+		}
+		value, ok := v.(string)
+		if !ok {
+			return "", &ParseError{Line: s.line, Record: number, Text: text,
+				Err: fmt.Errorf("%w: path %q", ErrField, path)}
+		}
+		return value, nil
+	}
+
+	return rs
+}
+
+// Scan advances to the next record with a valid url, returning false at
+// the end of input, on a read error, or on an invalid record with
+// StopOnError
+func (s *RecordScanner) Scan() bool {
+
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		var rec Record
+		text, err := s.next(&rec)
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				if err != io.EOF {
+					s.err = err
+				}
+				return false
+			}
+		}
+
+		s.Stats.Total++
+		if err == nil && !rec.URL.Parse(text) {
+			err = &ParseError{Line: rec.Line, Record: rec.Number, Text: text, Err: ErrInvalid}
+		}
+		if err != nil {
+			s.Stats.Invalid++
+			if s.StopOnError {
+				s.err = err
+				return false
+			}
+			continue
+		}
+
+		s.Stats.Valid++
+		if rec.URL.IP {
+			s.Stats.IP++
+		}
+		if rec.URL.IDNA {
+			s.Stats.IDNA++
+		}
+		s.rec = rec
+		return true
+	}
+}
+
+// Record returns the record of the last successful Scan
+func (s *RecordScanner) Record() Record { return s.rec }
+
+// Err returns the first read error or *ParseError; nil at end of input
+func (s *RecordScanner) Err() error { return s.err }
@@ -0,0 +1,123 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestRecordScanner(t *testing.T) {
+
+	csv := "id,URL,note\n" +
+		"1,example.com/a,first\n" +
+		"2,bad,second\n" +
+		"3,\"sub.example.org/b\",\"multi\nline\"\n" +
+		"4\n" +
+		"5,1.2.3.4,fifth\n"
+
+	s := url.NewCSVScanner(strings.NewReader(csv), "url")
+	var got []url.Record
+	for s.Scan() {
+		got = append(got, s.Record())
+	}
+	if s.Err() != nil || len(got) != 3 {
+		t.Fatal(s.Err(), got)
+	}
+	for i, v := range []struct {
+		line, number int
+		host, note   string
+	}{{2, 1, "example.com", "first"}, {4, 3, "sub.example.org", "multi\nline"}, {7, 5, "1.2.3.4", "fifth"}} {
+		if got[i].Line != v.line || got[i].Number != v.number || got[i].URL.Host != v.host || got[i].Fields[2] != v.note {
+			t.Errorf("%d: %+v", i, got[i])
+		}
+	}
+	if s.Stats != (url.Stats{Total: 5, Valid: 3, Invalid: 2, IP: 1}) {
+		t.Errorf("%+v", s.Stats)
+	}
+
+	// stop on the first invalid record with line and record numbers
+	s = url.NewCSVScanner(strings.NewReader(csv), "url")
+	s.StopOnError = true
+	for s.Scan() {
+	}
+	var perr *url.ParseError
+	if !errors.As(s.Err(), &perr) || perr.Line != 3 || perr.Record != 2 || perr.Text != "bad" || !errors.Is(s.Err(), url.ErrInvalid) {
+		t.Fatal(s.Err())
+	}
+
+	s = url.NewCSVScanner(strings.NewReader(csv), "link")
+	if s.Scan() || !errors.Is(s.Err(), url.ErrField) {
+		t.Fatal(s.Err())
+	}
+
+	// an empty stream has no records and no error
+	for _, empty := range []string{"", "\n"} {
+		s = url.NewCSVScanner(strings.NewReader(empty), "url")
+		if s.Scan() || s.Err() != nil || s.Stats.Total != 0 {
+			t.Fatalf("%q: %v", empty, s.Err())
+		}
+	}
+
+	s = url.NewTSVScanner(strings.NewReader("url\tcount\nexample.com/\"x\"\t3\n"), "URL")
+	if !s.Scan() || s.Record().URL.Path != "\"x\"" || s.Record().Fields[1] != "3" {
+		t.Fatal(s.Err(), s.Record())
+	}
+
+	jsonl := `{"ts":1,"request":{"url":"http://example.com/a"}}` + "\n\n" +
+		`{"ts":2,"request":{"url":42}}` + "\n" +
+		`{not json}` + "\n" +
+		`{"ts":3,"request":{"url":"bücher.example"}}`
+
+	s = url.NewJSONLScanner(strings.NewReader(jsonl), "request.url")
+	got = got[:0]
+	for s.Scan() {
+		got = append(got, s.Record())
+	}
+	if s.Err() != nil || len(got) != 2 || got[0].URL.Host != "example.com" || got[1].URL.Host != "xn--bcher-kva.example" {
+		t.Fatal(s.Err(), got)
+	}
+	if got[1].Line != 5 || got[1].Number != 4 || got[1].Object["ts"] != json.Number("3") {
+		t.Fatalf("%+v", got[1])
+	}
+
+	s = url.NewJSONLScanner(strings.NewReader(jsonl), "request.url")
+	s.StopOnError = true
+	for s.Scan() {
+	}
+	if !errors.As(s.Err(), &perr) || perr.Line != 3 || perr.Record != 2 || !errors.Is(s.Err(), url.ErrField) {
+		t.Fatal(s.Err())
+	}
+
+	// lines over MaxLine are reported as too long
+	s = url.NewJSONLScanner(strings.NewReader(jsonl), "request.url")
+	s.MaxLine = 40
+	s.StopOnError = true
+	if s.Scan() || !errors.As(s.Err(), &perr) || perr.Line != 1 || !errors.Is(s.Err(), url.ErrLineTooLong) {
+		t.Fatal(s.Err())
+	}
+}
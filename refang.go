@@ -0,0 +1,179 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import "strings"
+
+/*
+
+	url.URL refanging of defanged indicators before parsing, and
+	defanging for safe display; patterns match case insensitively
+
+	defanged                                refanged
+	--------------------------------------  --------
+	hxxp hxp h**p hXXp                      http      before s, : or [:
+	fxp                                     ftp       before s, : or [:
+	[.] (.) {.} [dot] (dot) {dot} \.        .         also with spaces around, eg. " [dot] "
+	[:]                                     :
+	[://] [:]//                             ://
+	[/]                                     /
+	[@] [at] (at)                           @
+
+	url.Refang("hxxps://evil[.]com/x")      // https://evil.com/x
+	url.Defang("see https://evil.com/x")    // see hxxps://evil[.]com/x
+	u.ParseRefang("1.2.3[.]4")
+	url.ExtractRefang("evil[dot]com and hxxp://a(.)b.net/") // offsets of the defanged text
+
+*/
+
+// refangs is the replacement table; longer forms first
+var refangs = []struct{ from, to string }{
+	{" [dot] ", "."}, {" (dot) ", "."}, {" {dot} ", "."}, {" [.] ", "."},
+	{"[dot]", "."}, {"(dot)", "."}, {"{dot}", "."},
+	{"[.]", "."}, {"(.)", "."}, {"{.}", "."}, {`\.`, "."},
+	{"[://]", "://"}, {"[:]//", "://"}, {"[:]", ":"}, {"[/]", "/"},
+	{"[@]", "@"}, {"[at]", "@"}, {"(at)", "@"},
+}
+
+// refangSchemes are the defanged scheme names
+var refangSchemes = []struct{ from, to string }{
+	{"hxxp", "http"}, {"hxp", "http"}, {"h**p", "http"}, {"fxp", "ftp"},
+}
+
+// Refang returns s with the defanged forms restored
+func Refang(s string) string {
+	r, _ := refang(s)
+	return r
+}
+
+// refang returns the refanged s and the offset in s where each byte of
+// the result starts; the map has a final entry of len(s) so an end
+// offset maps to the start of whatever follows it
+func refang(s string) (string, []int) {
+
+	var b strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+	emit := func(t string, at int) {
+		b.WriteString(t)
+		for j := 0; j < len(t); j++ {
+			offsets = append(offsets, at)
+		}
+	}
+
+next:
+	for i := 0; i < len(s); {
+		for _, v := range refangs {
+			if hasPrefixFold(s[i:], v.from) {
+				emit(v.to, i)
+				i += len(v.from)
+				continue next
+			}
+		}
+		if i == 0 || !isLetter(s[i-1]) {
+			for _, v := range refangSchemes {
+				if n := len(v.from); hasPrefixFold(s[i:], v.from) && refangScheme(s[i+n:]) {
+					emit(v.to, i)
+					i += n
+					continue next
+				}
+			}
+		}
+		b.WriteByte(s[i])
+		offsets = append(offsets, i)
+		i++
+	}
+
+	return b.String(), append(offsets, len(s))
+}
+
+// refangScheme reports if the rest after a defanged scheme name is an
+// optional s followed by : or [:
+func refangScheme(rest string) bool {
+	if len(rest) > 0 && (rest[0] == 's' || rest[0] == 'S') {
+		rest = rest[1:]
+	}
+	return strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "[:")
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// ParseRefang refangs the url and then parses it as Parse
+func (u *URL) ParseRefang(url string) bool {
+	return u.Parse(Refang(url))
+}
+
+// ExtractRefang returns the urls found in the refanged text; Start, End
+// and Text refer to the original text
+func ExtractRefang(text string) []Match {
+
+	refanged, offsets := refang(text)
+	matches := Extract(refanged)
+	for i := range matches {
+		m := &matches[i]
+		m.Start, m.End = offsets[m.Start], offsets[m.End]
+		m.Text = text[m.Start:m.End]
+	}
+
+	return matches
+}
+
+// Defang returns text with the urls found by Extract defanged for safe
+// display; the scheme becomes hxxp, hxxps or fxp and host dots [.]
+func Defang(text string) string {
+
+	var b strings.Builder
+	var last int
+	for _, m := range Extract(text) {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(defang(m.Text))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+// defang defangs the scheme and host of a single url
+func defang(s string) string {
+
+	var scheme string
+	if i := strings.Index(s, "://"); i > -1 {
+		scheme, s = s[:i], s[i+3:]
+		switch strings.ToLower(scheme) {
+		case "http", "https":
+			scheme = "hxxp" + scheme[4:]
+		case "ftp", "ftps":
+			scheme = "fxp" + scheme[3:]
+		}
+		scheme += "://"
+	}
+
+	host, rest := s, ""
+	if i := strings.IndexAny(s, "/?#"); i > -1 {
+		host, rest = s[:i], s[i:]
+	}
+
+	return scheme + strings.Replace(host, ".", "[.]", -1) + rest
+}
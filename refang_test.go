@@ -0,0 +1,83 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestRefang(t *testing.T) {
+
+	for in, out := range map[string]string{
+		"hxxp://evil[.]com":               "http://evil.com",
+		"HXXPS[://]evil(.)com[/]x":        "httpS://evil.com/x",
+		"h**p://a{.}b[dot]c(dot)d{dot}e":  "http://a.b.c.d.e",
+		"fxp[:]//files\\.example\\.net/":  "ftp://files.example.net/",
+		"evil [dot] com and 1.2.3[.]4":    "evil.com and 1.2.3.4",
+		"user[@]mail[.]com, user[at]x.io": "user@mail.com, user@x.io",
+		"hxp://x.com and ahxxp:// stays":  "http://x.com and ahxxp:// stays",
+		"plain.example.com/hxxp-page":     "plain.example.com/hxxp-page",
+	} {
+		if got := url.Refang(in); got != out {
+			t.Errorf("%q: got %q, want %q", in, got, out)
+		}
+	}
+
+	var u url.URL
+	if !u.ParseRefang("hxxps://evil[.]example[.]com/x") || u.Host != "evil.example.com" || u.Scheme != "https" {
+		t.Fatal(u)
+	}
+	if u.Parse("1.2.3[.]4"); u.IP {
+		t.Fatal(u)
+	}
+	if !u.ParseRefang("1.2.3[.]4") || !u.IP || u.Host != "1.2.3.4" {
+		t.Fatal(u)
+	}
+
+	text := "ioc: hxxp://evil[.]com/a, example(.)com and 10.0.0[.]1."
+	matches := url.ExtractRefang(text)
+	expect := []struct{ Text, Host string }{
+		{"hxxp://evil[.]com/a", "evil.com"},
+		{"example(.)com", "example.com"},
+		{"10.0.0[.]1", "10.0.0.1"},
+	}
+	if len(matches) != len(expect) {
+		t.Fatal(matches)
+	}
+	for i, m := range matches {
+		if m.Text != expect[i].Text || text[m.Start:m.End] != m.Text || m.URL.Host != expect[i].Host {
+			t.Errorf("%d: %q %q", i, m.Text, m.URL.Host)
+		}
+	}
+
+	text = "see https://evil.example.com/x.html?a=b and 10.1.2.3, [2001:db8::1]"
+	defanged := url.Defang(text)
+	if defanged != "see hxxps://evil[.]example[.]com/x.html?a=b and 10[.]1[.]2[.]3, [2001:db8::1]" {
+		t.Fatal(defanged)
+	}
+	if url.Refang(defanged) != text || len(url.Extract(defanged)) != 1 {
+		t.Fatal(url.Refang(defanged), url.Extract(defanged))
+	}
+}
@@ -0,0 +1,200 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+
+	url.URL relative reference resolution per RFC 3986 section 5.2
+	against the query as given to Parse; the fragment is dropped and
+	the result is parsed as Parse so the Host/Path/Page split, IDNA and
+	port rules apply
+
+	var base url.URL
+	base.Parse("http://a.example/b/c/d;p?q")
+	u, err := base.Resolve("../g")              // a.example/b/g
+	u, err = base.Resolve("//cdn.example.net/x.js") // cdn.example.net/x.js
+	u, err = base.Resolve("")
+	u.Absolute() // http://a.example/b/c/d;p?q
+
+*/
+
+// ErrReference reports a reference that does not resolve to a url with a host
+var ErrReference = errors.New("url: reference does not resolve to a url with a host")
+
+// Resolve the reference against the url per RFC 3986 section 5.2; the
+// scheme defaults to http when the url has none
+func (u *URL) Resolve(ref string) (URL, error) {
+
+	if len(u.Host) == 0 {
+		return URL{}, fmt.Errorf("%w: base has no host", ErrReference)
+	}
+
+	// base components
+	scheme := u.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+	authority := u.Host
+	if u.ipv6 {
+		authority = "[" + authority + "]"
+	}
+	if len(u.Port) > 0 {
+		authority += ":" + u.Port
+	}
+	var path string
+	if len(u.Path) > 0 || len(u.Page) > 0 {
+		path = "/" + u.Path
+		if len(u.Page) > 0 {
+			path += "/" + u.Page
+		}
+	}
+	query := u.query()
+	hasQuery := len(query) > 0 || u.forceQuery
+
+	// transform references; section 5.2.2
+	r := parseReference(ref)
+	switch {
+	case r.hasScheme:
+		scheme, authority = r.scheme, r.authority
+		if !r.hasAuthority {
+			authority = ""
+		}
+		path = removeDotSegments(r.path)
+		query, hasQuery = r.query, r.hasQuery
+	case r.hasAuthority:
+		authority = r.authority
+		path = removeDotSegments(r.path)
+		query, hasQuery = r.query, r.hasQuery
+	case len(r.path) == 0:
+		if r.hasQuery {
+			query, hasQuery = r.query, true
+		}
+	default:
+		if strings.HasPrefix(r.path, "/") {
+			path = removeDotSegments(r.path)
+		} else {
+			path = removeDotSegments(merge(path, r.path))
+		}
+		query, hasQuery = r.query, r.hasQuery
+	}
+
+	if len(authority) == 0 {
+		return URL{}, fmt.Errorf("%w: %q", ErrReference, ref)
+	}
+
+	s := scheme + "://" + authority + path
+	if hasQuery { // a defined but empty query is kept; section 5.3
+		s += "?" + query
+	}
+
+	var target URL
+	if !target.Parse(s) {
+		return URL{}, fmt.Errorf("%w: %q", ErrReference, ref)
+	}
+
+	return target, nil
+}
+
+// reference is a uri reference split per RFC 3986 appendix B; the
+// fragment is not retained
+type reference struct {
+	scheme, authority, path, query    string
+	hasScheme, hasAuthority, hasQuery bool
+}
+
+func parseReference(s string) (r reference) {
+
+	if i := strings.IndexByte(s, '#'); i > -1 {
+		s = s[:i]
+	}
+	if i := strings.IndexAny(s, ":/?"); i > 0 && s[i] == ':' {
+		r.scheme, s, r.hasScheme = s[:i], s[i+1:], true
+	}
+	if strings.HasPrefix(s, "//") {
+		s = s[2:]
+		i := strings.IndexAny(s, "/?")
+		if i < 0 {
+			i = len(s)
+		}
+		r.authority, s, r.hasAuthority = s[:i], s[i:], true
+	}
+	if i := strings.IndexByte(s, '?'); i > -1 {
+		r.query, s, r.hasQuery = s[i+1:], s[:i], true
+	}
+	r.path = s
+
+	return r
+}
+
+// merge the relative path with the base path; section 5.2.3
+func merge(base, ref string) string {
+	if len(base) == 0 {
+		return "/" + ref
+	}
+	return base[:strings.LastIndex(base, "/")+1] + ref
+}
+
+// removeDotSegments removes "." and ".." segments; section 5.2.4
+func removeDotSegments(in string) string {
+
+	var out []string
+	for len(in) > 0 {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			i := strings.IndexByte(in[1:], '/') + 1
+			if i == 0 {
+				i = len(in)
+			}
+			out = append(out, in[:i])
+			in = in[i:]
+		}
+	}
+
+	return strings.Join(out, "")
+}
@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestResolve(t *testing.T) {
+
+	// RFC 3986 section 5.4 with host a.example as Parse requires a domain
+	var base url.URL
+	base.Parse("http://a.example/b/c/d;p?q")
+
+	for ref, want := range map[string]string{
+		// normal examples; 5.4.1
+		"g":           "http://a.example/b/c/g",
+		"./g":         "http://a.example/b/c/g",
+		"g/":          "http://a.example/b/c/g/",
+		"/g":          "http://a.example/g",
+		"//g.example": "http://g.example/", // empty path as root
+		"?y":          "http://a.example/b/c/d;p?y",
+		"g?y":         "http://a.example/b/c/g?y",
+		"g?":          "http://a.example/b/c/g?",
+		"#s":          "http://a.example/b/c/d;p?q#s",
+		"g#s":         "http://a.example/b/c/g#s",
+		"g?y#s":       "http://a.example/b/c/g?y#s",
+		";x":          "http://a.example/b/c/;x",
+		"g;x":         "http://a.example/b/c/g;x",
+		"g;x?y#s":     "http://a.example/b/c/g;x?y#s",
+		"":            "http://a.example/b/c/d;p?q",
+		".":           "http://a.example/b/c/",
+		"./":          "http://a.example/b/c/",
+		"..":          "http://a.example/b/",
+		"../":         "http://a.example/b/",
+		"../g":        "http://a.example/b/g",
+		"../..":       "http://a.example/",
+		"../../":      "http://a.example/",
+		"../../g":     "http://a.example/g",
+
+		// abnormal examples; 5.4.2
+		"../../../g":    "http://a.example/g",
+		"../../../../g": "http://a.example/g",
+		"/./g":          "http://a.example/g",
+		"/../g":         "http://a.example/g",
+		"g.":            "http://a.example/b/c/g.",
+		".g":            "http://a.example/b/c/.g",
+		"g..":           "http://a.example/b/c/g..",
+		"..g":           "http://a.example/b/c/..g",
+		"./../g":        "http://a.example/b/g",
+		"./g/.":         "http://a.example/b/c/g/",
+		"g/./h":         "http://a.example/b/c/g/h",
+		"g/../h":        "http://a.example/b/c/h",
+		"g;x=1/./y":     "http://a.example/b/c/g;x=1/y",
+		"g;x=1/../y":    "http://a.example/b/c/y",
+		"g?y/./x":       "http://a.example/b/c/g?y/./x",
+		"g?y/../x":      "http://a.example/b/c/g?y/../x",
+		"g#s/./x":       "http://a.example/b/c/g#s/./x",
+		"g#s/../x":      "http://a.example/b/c/g#s/../x",
+
+		// package rules; IDNA, ports and the page split
+		"HTTPS://Bücher.example:443/x/logo.jpg": "https://xn--bcher-kva.example/x/logo.jpg",
+		"//[2001:db8::1]:8080/a":                "http://[2001:db8::1]:8080/a",
+		"../img/a.png":                          "http://a.example/b/img/a.png",
+	} {
+		got, err := base.Resolve(ref)
+		if err != nil {
+			t.Errorf("%q: %v", ref, err)
+			continue
+		}
+		if i := strings.Index(want, "#"); i > -1 {
+			want = want[:i] // the fragment is dropped
+		}
+		if got.Absolute() != want {
+			t.Errorf("%q: got %s, want %s", ref, got.Absolute(), want)
+		}
+	}
+
+	// non-hierarchical results; the strict "http:g" included
+	for _, ref := range []string{"g:h", "http:g", "mailto:a@example.com", "javascript:alert(1)", "//g"} {
+		if u, err := base.Resolve(ref); !errors.Is(err, url.ErrReference) {
+			t.Errorf("%q: %+v %v", ref, u, err)
+		}
+	}
+
+	// the base port and a base without a path
+	base.Parse("a.example:8080")
+	if u, err := base.Resolve("x/y.html"); err != nil || u.String() != "a.example:8080/x/y.html" || u.Page != "y.html" {
+		t.Error(u, err)
+	}
+	// the base query is resolved as given, not in its canonical form
+	base.Parse("http://a.example/p?b=2&a")
+	if u, err := base.Resolve("#f"); err != nil || u.Absolute() != "http://a.example/p?b=2&a" || u.Query != "a=&b=2" {
+		t.Error(u.Absolute(), u.Query, err)
+	}
+	base.Parse("http://a.example/p?")
+	if u, err := base.Resolve("#f"); err != nil || u.Absolute() != "http://a.example/p?" {
+		t.Error(u.Absolute(), err)
+	}
+	var empty url.URL
+	if _, err := empty.Resolve("g"); !errors.Is(err, url.ErrReference) {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/*
+
+	url.URL reverse dns names for ip urls; fully qualified with the
+	trailing dot, and parsed back case insensitively with or without it
+
+	u.Parse("192.0.2.1")
+	url.ReverseName(&u)                 // 1.2.0.192.in-addr.arpa.
+	u, err := url.ParseReverseName("1.0.0.0...8.b.d.0.1.0.0.2.ip6.arpa")
+
+*/
+
+// ErrReverse reports a url that is not an ip or a name that is not a
+// full in-addr.arpa or ip6.arpa reverse name
+var ErrReverse = errors.New("url: no reverse name")
+
+// reverse dns zones
+const (
+	inAddrArpa = ".in-addr.arpa"
+	ip6Arpa    = ".ip6.arpa"
+)
+
+// ReverseName returns the PTR name of an ip url; IPv4-mapped IPv6
+// addresses use in-addr.arpa
+func ReverseName(u *URL) (string, error) {
+
+	ip := net.ParseIP(u.Host)
+	if !u.IP || ip == nil {
+		return "", fmt.Errorf("%w: %q", ErrReverse, u.Host)
+	}
+
+	var b strings.Builder
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := 3; i >= 0; i-- {
+			b.WriteString(strconv.Itoa(int(ip4[i])))
+			b.WriteByte('.')
+		}
+		b.WriteString(inAddrArpa[1:])
+		return b.String() + ".", nil
+	}
+
+	const hex = "0123456789abcdef"
+	for i := len(ip) - 1; i >= 0; i-- {
+		b.WriteByte(hex[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hex[ip[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString(ip6Arpa[1:])
+	return b.String() + ".", nil
+}
+
+// ParseReverseName parses a PTR name into an ip url as Parse would
+func ParseReverseName(name string) (URL, error) {
+
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+
+	var ip net.IP
+	switch {
+	case strings.HasSuffix(name, inAddrArpa):
+		labels := strings.Split(strings.TrimSuffix(name, inAddrArpa), ".")
+		if len(labels) != 4 {
+			break
+		}
+		ip = make(net.IP, 4)
+		for i, label := range labels {
+			n, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || (len(label) > 1 && label[0] == '0') {
+				ip = nil
+				break
+			}
+			ip[3-i] = byte(n)
+		}
+
+	case strings.HasSuffix(name, ip6Arpa):
+		labels := strings.Split(strings.TrimSuffix(name, ip6Arpa), ".")
+		if len(labels) != 32 {
+			break
+		}
+		ip = make(net.IP, 16)
+		for i, label := range labels {
+			n, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				ip = nil
+				break
+			}
+			if i%2 == 0 {
+				ip[15-i/2] |= byte(n)
+			} else {
+				ip[15-i/2] |= byte(n) << 4
+			}
+		}
+	}
+
+	var u URL
+	if ip == nil || !u.Parse(ip.String()) {
+		return URL{}, fmt.Errorf("%w: %q", ErrReverse, name)
+	}
+
+	return u, nil
+}
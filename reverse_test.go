@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestReverseName(t *testing.T) {
+
+	for _, v := range []struct {
+		in, name, host string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa.", "192.0.2.1"},
+		{"http://10.0.0.255:8080/path", "255.0.0.10.in-addr.arpa.", "10.0.0.255"},
+		{"[2001:db8::1]:443", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "2001:db8::1"},
+		{"2001:DB8:0:0:0:0:0:1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", "2001:db8::1"},
+		{"::ffff:192.0.2.1", "1.2.0.192.in-addr.arpa.", "192.0.2.1"},
+	} {
+		var u url.URL
+		u.Parse(v.in)
+		name, err := url.ReverseName(&u)
+		if err != nil || name != v.name {
+			t.Errorf("%q: %q %v", v.in, name, err)
+			continue
+		}
+		back, err := url.ParseReverseName(name)
+		if err != nil || back.Host != v.host || !back.IP || back.String() != v.host {
+			t.Errorf("%q: %+v %v", name, back, err)
+		}
+	}
+
+	// case and trailing dot are optional
+	if u, err := url.ParseReverseName("1.2.0.192.IN-ADDR.ARPA"); err != nil || u.Host != "192.0.2.1" {
+		t.Error(u, err)
+	}
+
+	var u url.URL
+	u.Parse("example.com")
+	if _, err := url.ReverseName(&u); !errors.Is(err, url.ErrReverse) {
+		t.Error(err)
+	}
+	for _, name := range []string{
+		"2.0.192.in-addr.arpa", "1.2.0.256.in-addr.arpa.", "01.2.0.192.in-addr.arpa", "a.2.0.192.in-addr.arpa",
+		"1.0.8.b.d.0.1.0.0.2.ip6.arpa.", "example.com.",
+		"10.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	} {
+		if u, err := url.ParseReverseName(name); !errors.Is(err, url.ErrReverse) {
+			t.Errorf("%q: %+v %v", name, u, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"strings"
+
+	"github.com/zxdev/url/v2/safebrowsing"
+)
+
+/*
+
+	url.URL conversion to and from the safebrowsing canonical form
+	so one record type flows through the pipeline
+
+	u, err := url.ParseSafeBrowsing("http://0x7f.1/%7Ea/./b.html?y=2&x=1")
+	u.Host, u.Path, u.Page, u.Query // 127.0.0.1 ~a b.html x=1&y=2
+
+	c, err := url.ToSafeBrowsing(&u)
+	c.String() // http://127.0.0.1/~a/b.html?y=2&x=1
+
+*/
+
+// ParseSafeBrowsing parses the url with the safebrowsing canonicalization
+// rules; ports are dropped and the scheme defaults to http
+func ParseSafeBrowsing(url string) (URL, error) {
+	c, err := safebrowsing.ParseURL(url)
+	if err != nil {
+		return URL{}, err
+	}
+	return FromSafeBrowsing(c), nil
+}
+
+// FromSafeBrowsing converts the safebrowsing canonical form to a URL
+func FromSafeBrowsing(c *safebrowsing.Canonical) (u URL) {
+
+	u.Scheme = strings.ToLower(c.Scheme)
+	u.Host = c.Host
+	u.IP = c.IP
+	u.IDNA = c.IDNA || strings.HasPrefix(u.Host, "xn--") // as Parse
+	if strings.HasPrefix(u.Host, "[") {
+		u.Host = strings.Trim(u.Host, "[]")
+		u.ipv6 = true
+	}
+
+	// the raw query is kept byte for byte as the patterns are hashed
+	// with it while Query is canonical as in Parse
+	u.Path, u.Page = splitPage(strings.TrimPrefix(c.Path, "/"))
+	u.rawQuery = c.RawQuery
+	if len(c.RawQuery) > 0 {
+		u.Query = canonicalQuery(c.RawQuery)
+	}
+	u.forceQuery = c.ForceQuery && len(c.RawQuery) == 0
+
+	return u
+}
+
+// ToSafeBrowsing converts the URL to the safebrowsing canonical form;
+// the scheme defaults to http and the port is dropped
+func ToSafeBrowsing(u *URL) (*safebrowsing.Canonical, error) {
+
+	var scheme = u.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+
+	var host = u.Host
+	if u.ipv6 {
+		host = "[" + host + "]"
+	}
+
+	var path = u.Path
+	if len(u.Page) > 0 {
+		path += "/" + u.Page
+	}
+
+	url := scheme + "://" + host + "/" + path
+	if query := u.query(); len(query) > 0 || u.forceQuery {
+		url += "?" + query
+	}
+
+	return safebrowsing.ParseURL(url)
+}
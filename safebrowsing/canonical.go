@@ -0,0 +1,83 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"net/url"
+	"strings"
+)
+
+// IPEncoding is the set of obfuscated IPv4 forms that were canonicalized
+// into dotted decimal notation.
+type IPEncoding uint8
+
+// IPEncoding flags; a plain dotted decimal address has none set.
+const (
+	IPOctal    IPEncoding = 1 << iota // a component with a leading zero, eg. 0300
+	IPHex                             // a component with a 0x prefix, eg. 0xc0
+	IPDword                           // a single 32-bit number, eg. 3232235777
+	IPShort                           // two or three components, eg. 192.168.257
+	IPTrailing                        // trailing text after a space, eg. "10.1.2.3 xy"
+)
+
+var ipEncodingNames = []string{"octal", "hex", "dword", "short", "trailing"}
+
+// String returns the encoding names joined by '|', eg. "octal|short".
+func (e IPEncoding) String() string {
+	var names []string
+	for i, name := range ipEncodingNames {
+		if e&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Canonical is a URL canonicalized with the Safe Browsing rules along with
+// what the canonicalization found. The embedded URL holds the Scheme, Host,
+// Path and RawQuery; the fragment, user info and port are removed.
+type Canonical struct {
+	*url.URL
+
+	IDNA          bool       // the host was converted from Unicode to punycode
+	IP            bool       // the host is an IPv4 address or bracketed IPv6 literal
+	IPEncoding    IPEncoding // the obfuscated IPv4 forms that were decoded
+	WWW           bool       // the host starts with "www."
+	DotsRemoved   bool       // leading, trailing or repeated host dots were removed
+	UnescapeDepth int        // rounds of percent-decoding until the URL was stable
+}
+
+// String returns the canonical form scheme://host/path?query; the query
+// is only present when the URL had one.
+func (c *Canonical) String() string {
+	s := c.Scheme + "://" + c.Host
+	if c.Path == "" {
+		s += "/"
+	} else {
+		s += c.Path
+	}
+	if c.RawQuery != "" || c.ForceQuery {
+		s += "?" + c.RawQuery
+	}
+	return s
+}
@@ -0,0 +1,443 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The logic below speaks the Safe Browsing v4 Update API:
+//	threatListUpdates:fetch keeps the local Database current
+//	fullHashes:find confirms local prefix hits against full hashes
+//
+// The server sets a minimum wait before the next request of either kind
+// and errors push the client into an exponential back-off; both are
+// honoured by refusing with ErrWait until they elapse. Full hash responses
+// are cached, positive ones per match and negative ones per prefix, for
+// the durations the server sets.
+//
+// The base URL is configurable so that tests and mirrors can stand in for
+// the Google endpoint.
+
+// DefaultBaseURL is the Google Safe Browsing API endpoint.
+const DefaultBaseURL = "https://safebrowsing.googleapis.com"
+
+// ErrWait is returned when a request is not allowed before the minimum
+// wait duration or back-off period elapses.
+var ErrWait = errors.New("safebrowsing: request not allowed before minimum wait")
+
+// Threat is a URL pattern confirmed against a full hash of a list.
+type Threat struct {
+	ThreatDescriptor
+	Pattern string
+}
+
+// Client fetches threat list updates into DB and confirms lookups.
+type Client struct {
+	BaseURL       string
+	APIKey        string
+	ClientID      string
+	ClientVersion string
+	HTTPClient    *http.Client
+	DB            *Database
+
+	now  func() time.Time
+	rand func() float64
+
+	mu     sync.Mutex
+	update waiter // threatListUpdates:fetch
+	find   waiter // fullHashes:find
+	hits   map[HashPrefix]positive
+	misses map[HashPrefix]time.Time
+}
+
+// waiter tracks the earliest next request and consecutive failures.
+type waiter struct {
+	next     time.Time
+	failures int
+}
+
+// positive is a cached full hash response.
+type positive struct {
+	lists  map[ThreatDescriptor]time.Time // expiry per list
+	expiry time.Time
+}
+
+// NewClient returns a Client tracking the lists in a new Database.
+func NewClient(baseURL, apiKey string, lists ...ThreatDescriptor) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	db := NewDatabase()
+	db.Track(lists...)
+	return &Client{
+		BaseURL:       strings.TrimSuffix(baseURL, "/"),
+		APIKey:        apiKey,
+		ClientID:      "zxdev-url",
+		ClientVersion: "2.0.0",
+		HTTPClient:    http.DefaultClient,
+		DB:            db,
+		now:           time.Now,
+		rand:          rand.Float64,
+		hits:          make(map[HashPrefix]positive),
+		misses:        make(map[HashPrefix]time.Time),
+	}
+}
+
+// NextUpdate returns the earliest time Update will contact the server.
+func (c *Client) NextUpdate() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update.next
+}
+
+// Update fetches and applies threat list updates for the tracked lists.
+func (c *Client) Update(ctx context.Context) error {
+	if err := c.reserve(&c.update); err != nil {
+		return err
+	}
+
+	req := fetchRequest{Client: c.clientInfo()}
+	for _, td := range c.DB.Lists() {
+		req.ListUpdateRequests = append(req.ListUpdateRequests, listUpdateRequest{
+			ThreatDescriptor: td,
+			State:            c.DB.State(td),
+			Constraints:      constraints{SupportedCompressions: []string{Raw, Rice}},
+		})
+	}
+
+	var resp FetchResponse
+	err := c.post(ctx, "threatListUpdates:fetch", &req, &resp)
+
+	c.mu.Lock()
+	c.settle(&c.update, err, resp.MinimumWaitDuration)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.DB.Update(&resp)
+}
+
+// Lookup returns the threats of the URL; the URL is canonicalized as in
+// ParseURL and its patterns checked against the local Database, any hit
+// is then confirmed with the cache or a fullHashes:find request.
+func (c *Client) Lookup(ctx context.Context, url string) ([]Threat, error) {
+	hashes, err := FullHashes(url)
+	if err != nil {
+		return nil, err
+	}
+
+	type hit struct {
+		PatternHash
+		lists map[ThreatDescriptor]HashPrefix
+	}
+	var hits []hit
+	for _, h := range hashes {
+		if lists := c.DB.LookupHash(h.Hash); len(lists) > 0 {
+			hits = append(hits, hit{h, lists})
+		}
+	}
+	if len(hits) == 0 {
+		return nil, nil
+	}
+
+	// confirm from the cache, collecting the prefixes it can not answer
+	var threats []Threat
+	query := make(map[HashPrefix]bool)
+	c.mu.Lock()
+	now := c.now()
+	for _, h := range hits {
+		// a positive entry, expired or for other lists, must be
+		// re-requested whatever the negative cache of its prefix says
+		p, cached := c.hits[h.Hash]
+		for td, prefix := range h.lists {
+			switch expiry, ok := p.lists[td]; {
+			case ok && now.Before(expiry):
+				threats = append(threats, Threat{td, h.Pattern})
+			case cached || !c.negative(prefix, now):
+				query[prefix] = true
+			}
+		}
+	}
+	c.mu.Unlock()
+	if len(query) == 0 {
+		return threats, nil
+	}
+
+	if err := c.findHashes(ctx, query); err != nil {
+		return threats, err
+	}
+
+	threats = threats[:0]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now = c.now()
+	for _, h := range hits {
+		p := c.hits[h.Hash]
+		for td := range h.lists {
+			if expiry, ok := p.lists[td]; ok && now.Before(expiry) {
+				threats = append(threats, Threat{td, h.Pattern})
+			}
+		}
+	}
+	return threats, nil
+}
+
+// negative reports if the prefix has an unexpired negative cache entry.
+func (c *Client) negative(prefix HashPrefix, now time.Time) bool {
+	expiry, ok := c.misses[prefix]
+	return ok && now.Before(expiry)
+}
+
+// findHashes requests the full hashes of the prefixes and caches the response.
+func (c *Client) findHashes(ctx context.Context, prefixes map[HashPrefix]bool) error {
+	if err := c.reserve(&c.find); err != nil {
+		return err
+	}
+
+	req := findRequest{Client: c.clientInfo()}
+	lists := c.DB.Lists()
+	add := func(list []string, v string) []string {
+		for _, s := range list {
+			if s == v {
+				return list
+			}
+		}
+		return append(list, v)
+	}
+	for _, td := range lists {
+		req.ClientStates = append(req.ClientStates, c.DB.State(td))
+		req.ThreatInfo.ThreatTypes = add(req.ThreatInfo.ThreatTypes, td.ThreatType)
+		req.ThreatInfo.PlatformTypes = add(req.ThreatInfo.PlatformTypes, td.PlatformType)
+		req.ThreatInfo.ThreatEntryTypes = add(req.ThreatInfo.ThreatEntryTypes, td.ThreatEntryType)
+	}
+	for prefix := range prefixes {
+		req.ThreatInfo.ThreatEntries = append(req.ThreatInfo.ThreatEntries, threatEntry{Hash: []byte(prefix)})
+	}
+
+	var resp findResponse
+	err := c.post(ctx, "fullHashes:find", &req, &resp)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settle(&c.find, err, resp.MinimumWaitDuration)
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	matched := make(map[HashPrefix]bool)
+	for _, m := range resp.Matches {
+		hash := HashPrefix(m.Threat.Hash)
+		if !hash.IsFull() {
+			continue
+		}
+		for prefix := range prefixes {
+			if hash.HasPrefix(prefix) {
+				matched[prefix] = true
+			}
+		}
+		expiry := now.Add(parseDuration(m.CacheDuration))
+		p, ok := c.hits[hash]
+		if !ok {
+			p.lists = make(map[ThreatDescriptor]time.Time)
+		}
+		p.lists[m.ThreatDescriptor] = expiry
+		if expiry.After(p.expiry) {
+			p.expiry = expiry
+		}
+		c.hits[hash] = p
+	}
+	// prefixes with a match are not negatively cached so the full hash
+	// is re-requested once its positive entry expires and is dropped
+	expiry := now.Add(parseDuration(resp.NegativeCacheDuration))
+	for prefix := range prefixes {
+		if matched[prefix] {
+			delete(c.misses, prefix)
+			continue
+		}
+		c.misses[prefix] = expiry
+	}
+
+	// drop expired entries so the cache does not grow without bound
+	for hash, p := range c.hits {
+		if !now.Before(p.expiry) {
+			delete(c.hits, hash)
+		}
+	}
+	for prefix, expiry := range c.misses {
+		if !now.Before(expiry) {
+			delete(c.misses, prefix)
+		}
+	}
+	return nil
+}
+
+// reserve claims the next request of the waiter so concurrent callers
+// get ErrWait until settle records the outcome.
+func (c *Client) reserve(w *waiter) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Before(w.next) {
+		return ErrWait
+	}
+	w.next = now.Add(24 * time.Hour)
+	return nil
+}
+
+// settle records the outcome of a request; a failure backs off and a
+// success resets the failure count and honours the minimum wait.
+func (c *Client) settle(w *waiter, err error, wait string) {
+	now := c.now()
+	if err != nil {
+		w.failures++
+		w.next = now.Add(c.backoff(w.failures))
+		return
+	}
+	w.failures = 0
+	w.next = now.Add(parseDuration(wait))
+}
+
+// backoff returns MIN(2^(n-1) * 15 minutes * (RAND + 1), 24 hours).
+func (c *Client) backoff(n int) time.Duration {
+	d := math.Pow(2, float64(n-1)) * float64(15*time.Minute) * (c.rand() + 1)
+	if d > float64(24*time.Hour) {
+		return 24 * time.Hour
+	}
+	return time.Duration(d)
+}
+
+// post sends the JSON request to the API method and decodes the response.
+func (c *Client) post(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	endpoint := c.BaseURL + "/v4/" + method
+	if c.APIKey != "" {
+		endpoint += "?key=" + url.QueryEscape(c.APIKey)
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	hresp, err := hc.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(hresp.Body, 512))
+		return fmt.Errorf("safebrowsing: %s: %s: %s", method, hresp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(hresp.Body).Decode(resp)
+}
+
+func (c *Client) clientInfo() clientInfo {
+	return clientInfo{ClientID: c.ClientID, ClientVersion: c.ClientVersion}
+}
+
+// parseDuration parses the JSON form of a protobuf Duration, eg. "300.5s".
+func parseDuration(s string) time.Duration {
+	secs, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// Request and response wire types of the v4 REST API.
+
+type clientInfo struct {
+	ClientID      string `json:"clientId"`
+	ClientVersion string `json:"clientVersion"`
+}
+
+type constraints struct {
+	MaxUpdateEntries      int32    `json:"maxUpdateEntries,omitempty"`
+	MaxDatabaseEntries    int32    `json:"maxDatabaseEntries,omitempty"`
+	Region                string   `json:"region,omitempty"`
+	SupportedCompressions []string `json:"supportedCompressions"`
+}
+
+type listUpdateRequest struct {
+	ThreatDescriptor
+	State       string      `json:"state,omitempty"`
+	Constraints constraints `json:"constraints"`
+}
+
+type fetchRequest struct {
+	Client             clientInfo          `json:"client"`
+	ListUpdateRequests []listUpdateRequest `json:"listUpdateRequests"`
+}
+
+type threatEntry struct {
+	Hash []byte `json:"hash,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+type threatInfo struct {
+	ThreatTypes      []string      `json:"threatTypes"`
+	PlatformTypes    []string      `json:"platformTypes"`
+	ThreatEntryTypes []string      `json:"threatEntryTypes"`
+	ThreatEntries    []threatEntry `json:"threatEntries"`
+}
+
+type findRequest struct {
+	Client       clientInfo `json:"client"`
+	ClientStates []string   `json:"clientStates"`
+	ThreatInfo   threatInfo `json:"threatInfo"`
+}
+
+type threatMatch struct {
+	ThreatDescriptor
+	Threat        threatEntry `json:"threat"`
+	CacheDuration string      `json:"cacheDuration"`
+}
+
+type findResponse struct {
+	Matches               []threatMatch `json:"matches"`
+	MinimumWaitDuration   string        `json:"minimumWaitDuration"`
+	NegativeCacheDuration string        `json:"negativeCacheDuration"`
+}
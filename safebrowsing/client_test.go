@@ -0,0 +1,252 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// standIn serves the Update API from the recorded payloads.
+type standIn struct {
+	t     *testing.T
+	fail  bool
+	finds int
+	bad   HashPrefix
+
+	positive, negative string // cache durations; default 300s and 60s
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Query().Get("key") != "test-key" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if s.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v4/threatListUpdates:fetch":
+		var req fetchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ListUpdateRequests) != 1 {
+			s.t.Error("fetch request", err)
+		}
+		name := "testdata/full_update.json"
+		if req.ListUpdateRequests[0].State != "" {
+			name = "testdata/partial_update.json"
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		w.Write(b)
+
+	case "/v4/fullHashes:find":
+		s.finds++
+		var req findRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ThreatInfo.ThreatEntries) == 0 {
+			s.t.Error("find request", err)
+		}
+		resp := findResponse{NegativeCacheDuration: or(s.negative, "60s")}
+		for _, e := range req.ThreatInfo.ThreatEntries {
+			if s.bad.HasPrefix(HashPrefix(e.Hash)) {
+				resp.Matches = append(resp.Matches, threatMatch{
+					ThreatDescriptor: ThreatDescriptor{ThreatType: "MALWARE", PlatformType: "ANY_PLATFORM", ThreatEntryType: "URL"},
+					Threat:           threatEntry{Hash: []byte(s.bad)},
+					CacheDuration:    or(s.positive, "300s"),
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(&resp)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func TestClient(t *testing.T) {
+
+	si := &standIn{t: t, bad: FullHash("evil.example.com/bad/")}
+	srv := httptest.NewServer(si)
+	defer srv.Close()
+
+	now := time.Unix(1600000000, 0)
+	td := ThreatDescriptor{ThreatType: "MALWARE", PlatformType: "ANY_PLATFORM", ThreatEntryType: "URL"}
+	c := NewClient(srv.URL, "test-key", td)
+	c.now = func() time.Time { return now }
+	c.rand = func() float64 { return 0.5 }
+
+	// full update then the minimum wait before the partial update
+	ctx := context.Background()
+	if err := c.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.DB.State(td) != "c3RhdGUtMQ==" || !c.NextUpdate().Equal(now.Add(300500*time.Millisecond)) {
+		t.Fatal("full update", c.DB.State(td), c.NextUpdate())
+	}
+	if err := c.Update(ctx); !errors.Is(err, ErrWait) {
+		t.Fatal("expected minimum wait", err)
+	}
+	now = now.Add(301 * time.Second)
+	if err := c.Update(ctx); err != nil || c.DB.State(td) != "c3RhdGUtMg==" {
+		t.Fatal("partial update", err, c.DB.State(td))
+	}
+
+	for _, v := range []struct {
+		URL     string
+		Threats int
+		Finds   int
+	}{
+		{"http://evil.example.com/bad/file.exe", 1, 1},
+		{"https://a.evil.example.com/bad/", 1, 1},      // positive cache
+		{"http://phish.example.org/login/", 0, 2},      // confirmed safe
+		{"http://phish.example.org/login/index", 0, 2}, // negative cache
+		{"http://www.example.com/", 0, 2},              // no local prefix hit
+	} {
+		threats, err := c.Lookup(ctx, v.URL)
+		if err != nil || len(threats) != v.Threats || si.finds != v.Finds {
+			t.Fatal(v.URL, threats, err, si.finds)
+		}
+		if v.Threats > 0 && (threats[0].ThreatDescriptor != td || threats[0].Pattern != "evil.example.com/bad/") {
+			t.Fatal(v.URL, threats)
+		}
+	}
+	if _, err := c.Lookup(ctx, "http:evil.example.com/bad/"); err == nil {
+		t.Fatal("expected canonicalization error")
+	}
+
+	// negative entries expire before positive ones
+	now = now.Add(61 * time.Second)
+	if threats, _ := c.Lookup(ctx, "http://phish.example.org/login/"); len(threats) != 0 || si.finds != 3 {
+		t.Fatal("negative expiry", threats, si.finds)
+	}
+	if threats, _ := c.Lookup(ctx, "http://evil.example.com/bad/"); len(threats) != 1 || si.finds != 3 {
+		t.Fatal("positive cache", threats, si.finds)
+	}
+	now = now.Add(300 * time.Second)
+	if threats, _ := c.Lookup(ctx, "http://evil.example.com/bad/"); len(threats) != 1 || si.finds != 4 {
+		t.Fatal("positive expiry", threats, si.finds)
+	}
+
+	// failures back off exponentially up to a day
+	si.fail = true
+	now = now.Add(time.Hour)
+	for i, want := range []time.Duration{
+		22*time.Minute + 30*time.Second,
+		45 * time.Minute,
+		90 * time.Minute,
+	} {
+		if err := c.Update(ctx); err == nil || errors.Is(err, ErrWait) {
+			t.Fatal("expected failure", err)
+		}
+		if d := c.NextUpdate().Sub(now); d != want {
+			t.Fatal(i, d, want)
+		}
+		if err := c.Update(ctx); !errors.Is(err, ErrWait) {
+			t.Fatal("expected back-off", err)
+		}
+		now = c.NextUpdate()
+	}
+	if c.backoff(10) != 24*time.Hour {
+		t.Fatal("back-off cap", c.backoff(10))
+	}
+	si.fail = false
+	c.Update(ctx) // the replayed partial update fails its checksum
+	if c.update.failures != 0 {
+		t.Fatal("recovery", c.update.failures)
+	}
+
+	for in, want := range map[string]time.Duration{"300s": 300 * time.Second, "0.5s": 500 * time.Millisecond, "": 0, "x": 0} {
+		if d := parseDuration(in); d != want {
+			t.Error(in, d, want)
+		}
+	}
+}
+
+func TestClientPositiveExpiry(t *testing.T) {
+
+	si := &standIn{t: t, bad: FullHash("evil.example.com/bad/"), positive: "60s", negative: "600s"}
+	srv := httptest.NewServer(si)
+	defer srv.Close()
+
+	now := time.Unix(1600000000, 0)
+	td := ThreatDescriptor{ThreatType: "MALWARE", PlatformType: "ANY_PLATFORM", ThreatEntryType: "URL"}
+	c := NewClient(srv.URL, "test-key", td)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	if err := c.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// an expired positive entry is re-requested although the negative
+	// entry of its prefix outlives it, also once the entry is dropped
+	for i, step := range []time.Duration{0, 30 * time.Second, 90 * time.Second, 120 * time.Second} {
+		now = now.Add(step)
+		threats, err := c.Lookup(ctx, "http://evil.example.com/bad/")
+		if err != nil || len(threats) != 1 {
+			t.Fatal(i, threats, err)
+		}
+		if want := []int{1, 1, 2, 3}[i]; si.finds != want {
+			t.Fatal(i, "finds", si.finds, want)
+		}
+		if i == 2 {
+			c.mu.Lock()
+			delete(c.hits, FullHash("evil.example.com/bad/"))
+			c.mu.Unlock()
+		}
+	}
+}
+
+func TestClientReserve(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	c := NewClient("http://127.0.0.1:0", "test-key")
+	c.now = func() time.Time { return now }
+
+	// the first caller holds the request until it settles
+	if err := c.reserve(&c.find); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reserve(&c.find); !errors.Is(err, ErrWait) {
+		t.Fatal("expected reserved", err)
+	}
+	c.settle(&c.find, nil, "")
+	if err := c.reserve(&c.find); err != nil {
+		t.Fatal("expected released", err)
+	}
+}
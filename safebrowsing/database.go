@@ -0,0 +1,389 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The logic below keeps a local copy of Safe Browsing v4 threat lists.
+// Each list is the sorted set of hash prefixes the server sent, built by
+// a full update and then maintained with partial updates that remove
+// entries by their index in the sorted set and add new prefixes. After
+// every update the SHA-256 over the concatenated sorted prefixes must
+// equal the checksum the server sent; otherwise the list is cleared so
+// that the next fetch asks for a full update.
+//
+// The wire types follow the JSON form of the v4 REST API:
+//	https://developers.google.com/safe-browsing/v4/update-api
+
+// Update response types.
+const (
+	FullUpdate    = "FULL_UPDATE"
+	PartialUpdate = "PARTIAL_UPDATE"
+)
+
+// Threat entry set compression types.
+const (
+	Raw  = "RAW"
+	Rice = "RICE"
+)
+
+// ErrChecksum is returned when an updated list does not match the checksum
+// sent by the server; the list is cleared.
+var ErrChecksum = errors.New("safebrowsing: threat list checksum mismatch")
+
+// ThreatDescriptor identifies a threat list.
+type ThreatDescriptor struct {
+	ThreatType      string `json:"threatType"`
+	PlatformType    string `json:"platformType"`
+	ThreatEntryType string `json:"threatEntryType"`
+}
+
+// String returns the list name as threat/platform/entry.
+func (td ThreatDescriptor) String() string {
+	return td.ThreatType + "/" + td.PlatformType + "/" + td.ThreatEntryType
+}
+
+// RawHashes is a run of uncompressed hash prefixes of one size.
+type RawHashes struct {
+	PrefixSize int32  `json:"prefixSize"`
+	RawHashes  []byte `json:"rawHashes"`
+}
+
+// RawIndices is a list of uncompressed removal indices.
+type RawIndices struct {
+	Indices []int32 `json:"indices"`
+}
+
+// RiceDeltaEncoding is a Golomb-Rice encoded list of sorted integers.
+type RiceDeltaEncoding struct {
+	FirstValue    json.Number `json:"firstValue,omitempty"`
+	RiceParameter int32       `json:"riceParameter,omitempty"`
+	NumEntries    int32       `json:"numEntries,omitempty"`
+	EncodedData   []byte      `json:"encodedData,omitempty"`
+}
+
+// ThreatEntrySet is a set of additions or removals.
+type ThreatEntrySet struct {
+	CompressionType string             `json:"compressionType,omitempty"`
+	RawHashes       *RawHashes         `json:"rawHashes,omitempty"`
+	RawIndices      *RawIndices        `json:"rawIndices,omitempty"`
+	RiceHashes      *RiceDeltaEncoding `json:"riceHashes,omitempty"`
+	RiceIndices     *RiceDeltaEncoding `json:"riceIndices,omitempty"`
+}
+
+// Checksum is the expected SHA-256 of a list after an update.
+type Checksum struct {
+	SHA256 []byte `json:"sha256"`
+}
+
+// ListUpdateResponse is the update for a single threat list.
+type ListUpdateResponse struct {
+	ThreatDescriptor
+	ResponseType   string           `json:"responseType"`
+	Additions      []ThreatEntrySet `json:"additions,omitempty"`
+	Removals       []ThreatEntrySet `json:"removals,omitempty"`
+	NewClientState string           `json:"newClientState"`
+	Checksum       *Checksum        `json:"checksum,omitempty"`
+}
+
+// FetchResponse is the threatListUpdates:fetch response.
+type FetchResponse struct {
+	ListUpdateResponses []ListUpdateResponse `json:"listUpdateResponses"`
+	MinimumWaitDuration string               `json:"minimumWaitDuration,omitempty"`
+}
+
+// Match is a URL pattern whose full hash carries a listed prefix.
+type Match struct {
+	ThreatDescriptor
+	Pattern string
+	Hash    HashPrefix // full hash of the pattern
+	Prefix  HashPrefix // listed prefix
+}
+
+// threatList is the sorted prefix set of one list.
+type threatList struct {
+	state    string
+	prefixes []HashPrefix
+	lengths  []int // distinct prefix lengths
+}
+
+// Database is a local set of threat lists safe for concurrent use.
+type Database struct {
+	mu    sync.RWMutex
+	lists map[ThreatDescriptor]*threatList
+}
+
+// NewDatabase returns an empty Database.
+func NewDatabase() *Database {
+	return &Database{lists: make(map[ThreatDescriptor]*threatList)}
+}
+
+// Track adds empty lists so that their first fetch requests a full update.
+func (db *Database) Track(lists ...ThreatDescriptor) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, td := range lists {
+		if db.lists[td] == nil {
+			db.lists[td] = new(threatList)
+		}
+	}
+}
+
+// Lists returns the tracked lists sorted by name.
+func (db *Database) Lists() []ThreatDescriptor {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return sortedLists(db.lists)
+}
+
+// State returns the client state of the list; empty before a full update.
+func (db *Database) State(td ThreatDescriptor) string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if tl := db.lists[td]; tl != nil {
+		return tl.state
+	}
+	return ""
+}
+
+// Len returns the number of prefixes in the list.
+func (db *Database) Len(td ThreatDescriptor) int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if tl := db.lists[td]; tl != nil {
+		return len(tl.prefixes)
+	}
+	return 0
+}
+
+// Update applies every list update of a fetch response; all lists are
+// attempted and the first error is returned.
+func (db *Database) Update(resp *FetchResponse) error {
+	var first error
+	for i := range resp.ListUpdateResponses {
+		if err := db.Apply(&resp.ListUpdateResponses[i]); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Apply applies a single list update. A malformed update leaves the list
+// unchanged while a checksum mismatch clears it; both return an error.
+func (db *Database) Apply(lu *ListUpdateResponse) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var prefixes []HashPrefix
+	switch lu.ResponseType {
+	case FullUpdate:
+	case PartialUpdate:
+		old := db.lists[lu.ThreatDescriptor]
+		if old == nil || old.state == "" {
+			return fmt.Errorf("safebrowsing: partial update for %v without a full update", lu.ThreatDescriptor)
+		}
+		var err error
+		if prefixes, err = removeIndices(old.prefixes, lu.Removals); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("safebrowsing: unknown response type %q", lu.ResponseType)
+	}
+
+	for _, set := range lu.Additions {
+		add, err := decodeHashes(set)
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, add...)
+	}
+
+	var err error
+	tl := newThreatList(lu.NewClientState, prefixes)
+	if lu.Checksum != nil && !bytes.Equal(tl.checksum(), lu.Checksum.SHA256) {
+		tl, err = new(threatList), fmt.Errorf("%w: %v", ErrChecksum, lu.ThreatDescriptor)
+	}
+	db.lists[lu.ThreatDescriptor] = tl
+	return err
+}
+
+// Lookup returns the list matches of the URL patterns.
+func (db *Database) Lookup(url string) ([]Match, error) {
+	hashes, err := FullHashes(url)
+	if err != nil {
+		return nil, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var matches []Match
+	for _, td := range sortedLists(db.lists) {
+		tl := db.lists[td]
+		for _, h := range hashes {
+			if p, ok := tl.lookup(h.Hash); ok {
+				matches = append(matches, Match{ThreatDescriptor: td, Pattern: h.Pattern, Hash: h.Hash, Prefix: p})
+			}
+		}
+	}
+	return matches, nil
+}
+
+// LookupHash returns the lists holding a prefix of the full hash together
+// with the matching prefix.
+func (db *Database) LookupHash(hash HashPrefix) map[ThreatDescriptor]HashPrefix {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	found := make(map[ThreatDescriptor]HashPrefix)
+	for td, tl := range db.lists {
+		if p, ok := tl.lookup(hash); ok {
+			found[td] = p
+		}
+	}
+	return found
+}
+
+func sortedLists(lists map[ThreatDescriptor]*threatList) []ThreatDescriptor {
+	tds := make([]ThreatDescriptor, 0, len(lists))
+	for td := range lists {
+		tds = append(tds, td)
+	}
+	sort.Slice(tds, func(i, j int) bool { return tds[i].String() < tds[j].String() })
+	return tds
+}
+
+// newThreatList sorts and deduplicates the prefixes.
+func newThreatList(state string, prefixes []HashPrefix) *threatList {
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i] < prefixes[j] })
+	tl := &threatList{state: state}
+	seen := make(map[int]bool)
+	for i, p := range prefixes {
+		if i > 0 && p == prefixes[i-1] {
+			continue
+		}
+		tl.prefixes = append(tl.prefixes, p)
+		if !seen[len(p)] {
+			seen[len(p)] = true
+			tl.lengths = append(tl.lengths, len(p))
+		}
+	}
+	sort.Ints(tl.lengths)
+	return tl
+}
+
+// lookup returns the listed prefix of the full hash, if any.
+func (tl *threatList) lookup(hash HashPrefix) (HashPrefix, bool) {
+	for _, n := range tl.lengths {
+		p := hash.Prefix(n)
+		i := sort.Search(len(tl.prefixes), func(i int) bool { return tl.prefixes[i] >= p })
+		if i < len(tl.prefixes) && tl.prefixes[i] == p {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// checksum is the SHA-256 over the concatenated sorted prefixes.
+func (tl *threatList) checksum() []byte {
+	h := sha256.New()
+	for _, p := range tl.prefixes {
+		h.Write([]byte(p))
+	}
+	return h.Sum(nil)
+}
+
+// removeIndices returns a copy of prefixes without the removal indices.
+func removeIndices(prefixes []HashPrefix, removals []ThreatEntrySet) ([]HashPrefix, error) {
+	drop := make(map[int32]bool)
+	for _, set := range removals {
+		var indices []int32
+		switch set.CompressionType {
+		case Rice:
+			values, err := decodeRice(set.RiceIndices)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				indices = append(indices, int32(v))
+			}
+		case Raw, "":
+			if set.RawIndices != nil {
+				indices = set.RawIndices.Indices
+			}
+		default:
+			return nil, fmt.Errorf("safebrowsing: unknown compression type %q", set.CompressionType)
+		}
+		for _, i := range indices {
+			if i < 0 || int(i) >= len(prefixes) {
+				return nil, errors.New("safebrowsing: removal index out of range")
+			}
+			drop[i] = true
+		}
+	}
+	kept := make([]HashPrefix, 0, len(prefixes)-len(drop))
+	for i, p := range prefixes {
+		if !drop[int32(i)] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+// decodeHashes returns the prefixes of an addition set.
+func decodeHashes(set ThreatEntrySet) ([]HashPrefix, error) {
+	switch set.CompressionType {
+	case Rice:
+		values, err := decodeRice(set.RiceHashes)
+		if err != nil {
+			return nil, err
+		}
+		prefixes := make([]HashPrefix, len(values))
+		var buf [4]byte
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(buf[:], v)
+			prefixes[i] = HashPrefix(buf[:])
+		}
+		return prefixes, nil
+	case Raw, "":
+		if set.RawHashes == nil {
+			return nil, nil
+		}
+		n, raw := int(set.RawHashes.PrefixSize), set.RawHashes.RawHashes
+		if n < MinHashPrefixLength || n > MaxHashPrefixLength || len(raw)%n != 0 {
+			return nil, errors.New("safebrowsing: invalid raw hashes")
+		}
+		prefixes := make([]HashPrefix, 0, len(raw)/n)
+		for ; len(raw) > 0; raw = raw[n:] {
+			prefixes = append(prefixes, HashPrefix(raw[:n]))
+		}
+		return prefixes, nil
+	}
+	return nil, fmt.Errorf("safebrowsing: unknown compression type %q", set.CompressionType)
+}
@@ -0,0 +1,118 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fetch decodes a recorded update served by the local stand-in.
+func fetch(t *testing.T, srv *httptest.Server, name string) *FetchResponse {
+	resp, err := http.Get(srv.URL + "/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var fr FetchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&fr); err != nil {
+		t.Fatal(name, err)
+	}
+	return &fr
+}
+
+func TestDatabase(t *testing.T) {
+
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer srv.Close()
+
+	td := ThreatDescriptor{ThreatType: "MALWARE", PlatformType: "ANY_PLATFORM", ThreatEntryType: "URL"}
+	db := NewDatabase()
+	db.Track(td)
+	if db.State(td) != "" || len(db.Lists()) != 1 {
+		t.Fatal("expected empty tracked list")
+	}
+
+	partial := fetch(t, srv, "partial_update.json")
+	if err := db.Update(partial); err == nil {
+		t.Fatal("expected partial update without full update to fail")
+	}
+
+	if err := db.Update(fetch(t, srv, "full_update.json")); err != nil {
+		t.Fatal(err)
+	}
+	if db.State(td) != "c3RhdGUtMQ==" || db.Len(td) != 303 {
+		t.Fatal("full update", db.State(td), db.Len(td))
+	}
+
+	for _, v := range []struct {
+		URL     string
+		Pattern string
+	}{
+		{"http://evil.example.com/bad/file.exe", "evil.example.com/bad/"},
+		{"https://a.b.evil.example.com/bad/", "evil.example.com/bad/"},
+		{"tracker.example.org/pixel.gif", "tracker.example.org/"},
+		{"http://malware.example.net/x/y?z=1", "malware.example.net/"},
+		{"http://phish.example.org/login/", ""},
+		{"http://www.example.com/", ""},
+	} {
+		matches, err := db.Lookup(v.URL)
+		if err != nil {
+			t.Fatal(v.URL, err)
+		}
+		if v.Pattern == "" && len(matches) != 0 ||
+			v.Pattern != "" && (len(matches) != 1 || matches[0].Pattern != v.Pattern || matches[0].ThreatDescriptor != td) {
+			t.Fatal(v.URL, matches)
+		}
+	}
+
+	if err := db.Update(partial); err != nil {
+		t.Fatal(err)
+	}
+	if db.State(td) != "c3RhdGUtMg==" || db.Len(td) != 300 {
+		t.Fatal("partial update", db.State(td), db.Len(td))
+	}
+	if m, _ := db.Lookup("http://tracker.example.org/"); len(m) != 0 {
+		t.Fatal("removed prefix still matches", m)
+	}
+	if m, _ := db.Lookup("http://phish.example.org/login/index.html"); len(m) != 1 || len(m[0].Prefix) != 4 {
+		t.Fatal("added prefix does not match", m)
+	}
+	if found := db.LookupHash(FullHash("malware.example.net/")); len(found[td]) != 32 {
+		t.Fatal("full hash lookup", found)
+	}
+
+	// a corrupted checksum clears the list for a new full update
+	bad := fetch(t, srv, "full_update.json")
+	bad.ListUpdateResponses[0].Checksum.SHA256[0] ^= 0xff
+	if err := db.Update(bad); !errors.Is(err, ErrChecksum) {
+		t.Fatal("expected checksum error", err)
+	}
+	if db.State(td) != "" || db.Len(td) != 0 {
+		t.Fatal("list not cleared")
+	}
+
+}
@@ -0,0 +1,138 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// The logic below deals with hashing the patterns of a URL.
+// Safe Browsing lists hold the leading 4 to 32 bytes of the SHA-256
+// hash of each listed pattern; a client hashes every pattern of the URL
+// and looks the prefixes up, confirming any hit against the full hash.
+//
+// For example, the 4 byte prefixes for "http://a.b/1.html" are:
+//	a.b/1.html  =>  sha256 prefix of "a.b/1.html"
+//	a.b/        =>  sha256 prefix of "a.b/"
+
+// Hash prefix length bounds in bytes.
+const (
+	MinHashPrefixLength = 4
+	MaxHashPrefixLength = sha256.Size
+)
+
+// HashPrefix is a binary SHA-256 hash or a leading prefix of one.
+type HashPrefix string
+
+// FullHash returns the SHA-256 hash of the pattern.
+func FullHash(pattern string) HashPrefix {
+	sum := sha256.Sum256([]byte(pattern))
+	return HashPrefix(sum[:])
+}
+
+// HasPrefix reports whether p starts with prefix.
+func (p HashPrefix) HasPrefix(prefix HashPrefix) bool {
+	return strings.HasPrefix(string(p), string(prefix))
+}
+
+// IsFull reports whether p is a full SHA-256 hash.
+func (p HashPrefix) IsFull() bool {
+	return len(p) == MaxHashPrefixLength
+}
+
+// IsValid reports whether p is within the prefix length bounds.
+func (p HashPrefix) IsValid() bool {
+	return len(p) >= MinHashPrefixLength && len(p) <= MaxHashPrefixLength
+}
+
+// Prefix returns the leading n bytes of p, or p when it is shorter.
+func (p HashPrefix) Prefix(n int) HashPrefix {
+	if n < len(p) {
+		return p[:n]
+	}
+	return p
+}
+
+// String returns the hex encoding of p.
+func (p HashPrefix) String() string {
+	return hex.EncodeToString([]byte(p))
+}
+
+// PatternHash is a pattern and its full hash.
+type PatternHash struct {
+	Pattern string
+	Hash    HashPrefix
+}
+
+// PrefixHash is a hash prefix with the patterns whose full hash carries
+// it; there is more than one pattern only when the prefixes collide.
+type PrefixHash struct {
+	Prefix   HashPrefix
+	Patterns []PatternHash
+}
+
+// FullHashes returns the full hash of each pattern of the URL in the order
+// produced by GeneratePatterns.
+func FullHashes(url string) ([]PatternHash, error) {
+	patterns, err := generatePatterns(url)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]PatternHash, len(patterns))
+	for i, pattern := range patterns {
+		hashes[i] = PatternHash{Pattern: pattern, Hash: FullHash(pattern)}
+	}
+	return hashes, nil
+}
+
+// HashPrefixes returns the deduplicated n byte hash prefixes of the URL
+// patterns, sorted by prefix.
+func HashPrefixes(url string, n int) ([]PrefixHash, error) {
+	if n < MinHashPrefixLength || n > MaxHashPrefixLength {
+		return nil, errors.New("safebrowsing: invalid hash prefix length")
+	}
+	hashes, err := FullHashes(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []PrefixHash
+	index := make(map[HashPrefix]int, len(hashes))
+	for _, h := range hashes {
+		p := h.Hash.Prefix(n)
+		if i, ok := index[p]; ok {
+			prefixes[i].Patterns = append(prefixes[i].Patterns, h)
+			continue
+		}
+		index[p] = len(prefixes)
+		prefixes = append(prefixes, PrefixHash{Prefix: p, Patterns: []PatternHash{h}})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Prefix < prefixes[j].Prefix
+	})
+	return prefixes, nil
+}
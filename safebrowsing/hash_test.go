@@ -0,0 +1,70 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"crypto/sha256"
+	"sort"
+	"testing"
+)
+
+func TestHashPrefixes(t *testing.T) {
+
+	hashes, err := FullHashes("http://a.b.c/1/2.html?param=1")
+	if err != nil || len(hashes) != 8 {
+		t.Fatal(hashes, err)
+	}
+	for _, h := range hashes {
+		sum := sha256.Sum256([]byte(h.Pattern))
+		if h.Hash != HashPrefix(sum[:]) || !h.Hash.IsFull() {
+			t.Fatal("full hash", h.Pattern, h.Hash)
+		}
+	}
+
+	for _, n := range []int{4, 8, 32} {
+		prefixes, err := HashPrefixes("http://a.b.c/1/2.html?param=1", n)
+		if err != nil || len(prefixes) != 8 {
+			t.Fatal(n, prefixes, err)
+		}
+		if !sort.SliceIsSorted(prefixes, func(i, j int) bool { return prefixes[i].Prefix < prefixes[j].Prefix }) {
+			t.Fatal("prefixes not sorted")
+		}
+		for _, p := range prefixes {
+			if len(p.Prefix) != n || !p.Prefix.IsValid() || !p.Patterns[0].Hash.HasPrefix(p.Prefix) {
+				t.Fatal("prefix", p.Prefix, p.Patterns)
+			}
+		}
+	}
+
+	if h := FullHash("abc"); h.String() != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" ||
+		h.Prefix(4).String() != "ba7816bf" {
+		t.Fatal("hex", h)
+	}
+	if _, err := HashPrefixes("http://a.b.c/", 3); err == nil {
+		t.Fatal("expected invalid length error")
+	}
+	if _, err := HashPrefixes("http:///", 4); err == nil {
+		t.Fatal("expected url error")
+	}
+
+}
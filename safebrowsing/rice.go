@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import "errors"
+
+// The Update API compresses 4 byte hash prefixes and removal indices with
+// Golomb-Rice coding: the values are sorted, the first is sent as is and
+// every following one as the delta from its predecessor. Each delta is
+// split by the Rice parameter k into a quotient, written in unary as
+// q one bits closed by a zero bit, and a k bit remainder. Bits are packed
+// least significant first.
+
+// errRice is returned for truncated or malformed Rice data.
+var errRice = errors.New("safebrowsing: invalid rice encoding")
+
+// bitReader reads bits least significant first.
+type bitReader struct {
+	buf  []byte
+	mask byte
+}
+
+func newBitReader(buf []byte) *bitReader {
+	return &bitReader{buf: buf, mask: 0x01}
+}
+
+// ReadBits reads n bits into the low bits of v.
+func (br *bitReader) ReadBits(n int) (v uint32, err error) {
+	if n < 0 || n > 32 {
+		return 0, errRice
+	}
+	for i := 0; i < n; i++ {
+		if len(br.buf) == 0 {
+			return 0, errRice
+		}
+		if br.buf[0]&br.mask != 0 {
+			v |= 1 << uint(i)
+		}
+		if br.mask <<= 1; br.mask == 0 {
+			br.buf, br.mask = br.buf[1:], 0x01
+		}
+	}
+	return v, nil
+}
+
+// decodeRice decodes the first value and NumEntries deltas.
+func decodeRice(rice *RiceDeltaEncoding) ([]uint32, error) {
+	if rice == nil {
+		return nil, errRice
+	}
+	first, err := rice.FirstValue.Int64()
+	if rice.FirstValue == "" {
+		first, err = 0, nil
+	}
+	k := rice.RiceParameter
+	// every entry takes at least one bit, which bounds the server
+	// controlled NumEntries before it sizes an allocation
+	if err != nil || first < 0 || first > 1<<32-1 || rice.NumEntries < 0 ||
+		int64(rice.NumEntries) > int64(len(rice.EncodedData))*8 ||
+		rice.NumEntries > 0 && (k < 2 || k > 28) {
+		return nil, errRice
+	}
+
+	values := make([]uint32, 1, int(rice.NumEntries)+1)
+	values[0] = uint32(first)
+	br := newBitReader(rice.EncodedData)
+	for i := int32(0); i < rice.NumEntries; i++ {
+		var q uint32
+		for {
+			bit, err := br.ReadBits(1)
+			if err != nil {
+				return nil, err
+			}
+			if bit == 0 {
+				break
+			}
+			q++
+		}
+		r, err := br.ReadBits(int(k))
+		if err != nil {
+			return nil, err
+		}
+		v := uint64(values[len(values)-1]) + uint64(q)<<uint(k) + uint64(r)
+		if v > 1<<32-1 {
+			return nil, errRice // overflow
+		}
+		values = append(values, uint32(v))
+	}
+	return values, nil
+}
@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// encodeRice is the reference encoder for the sorted values.
+func encodeRice(values []uint32, k uint) *RiceDeltaEncoding {
+	rice := &RiceDeltaEncoding{
+		FirstValue:    json.Number(strconv.FormatUint(uint64(values[0]), 10)),
+		RiceParameter: int32(k),
+		NumEntries:    int32(len(values) - 1),
+	}
+	var n uint
+	put := func(bit uint32) {
+		if n%8 == 0 {
+			rice.EncodedData = append(rice.EncodedData, 0)
+		}
+		rice.EncodedData[n/8] |= byte(bit << (n % 8))
+		n++
+	}
+	for i := 1; i < len(values); i++ {
+		delta := values[i] - values[i-1]
+		for q := delta >> k; q > 0; q-- {
+			put(1)
+		}
+		put(0)
+		for j := uint(0); j < k; j++ {
+			put(delta >> j & 1)
+		}
+	}
+	return rice
+}
+
+func TestRice(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	for _, k := range []uint{2, 5, 17, 28} {
+		span := int64(1) << (k + 2)
+		if span > 1<<22 { // keep the sum in range
+			span = 1 << 22
+		}
+		values := make([]uint32, 500)
+		values[0] = r.Uint32() >> 1
+		for i := 1; i < len(values); i++ {
+			values[i] = values[i-1] + uint32(r.Int63n(span))
+		}
+
+		rice := encodeRice(values, k)
+		data, _ := json.Marshal(rice)
+		var wire RiceDeltaEncoding
+		if err := json.Unmarshal(data, &wire); err != nil {
+			t.Fatal(err)
+		}
+		out, err := decodeRice(&wire)
+		if err != nil || len(out) != len(values) {
+			t.Fatal(k, err, len(out))
+		}
+		for i := range out {
+			if out[i] != values[i] {
+				t.Fatal(k, i, out[i], values[i])
+			}
+		}
+
+		wire.EncodedData = wire.EncodedData[:len(wire.EncodedData)/2]
+		if _, err := decodeRice(&wire); err != errRice {
+			t.Fatal("expected truncation error", err)
+		}
+	}
+
+	// single value; no deltas and a numeric first value
+	var wire RiceDeltaEncoding
+	json.Unmarshal([]byte(`{"firstValue":42}`), &wire)
+	if out, err := decodeRice(&wire); err != nil || len(out) != 1 || out[0] != 42 {
+		t.Fatal(out, err)
+	}
+
+	// entry counts the encoded data can not hold are rejected before
+	// they size an allocation
+	for _, n := range []int32{math.MaxInt32, 1 << 30, 17} {
+		wire := RiceDeltaEncoding{RiceParameter: 2, NumEntries: n, EncodedData: []byte{0xff, 0xff}}
+		if out, err := decodeRice(&wire); err != errRice {
+			t.Fatal(n, len(out), err)
+		}
+	}
+
+}
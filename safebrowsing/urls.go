@@ -50,6 +50,7 @@ import (
 	"strings"
 
 	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 var (
@@ -71,11 +72,10 @@ func ValidURL(url string) bool {
 	return parsed != nil && err == nil
 }
 
-// ParseURL parses the given string and returns the results and true if the
-// url was Safe Browsing cmopatible.
-func ParseURL(url string) (*url.URL, bool) {
-	parsed, err := parseURL(url)
-	return parsed, parsed != nil && err == nil
+// ParseURL parses the given string and returns the canonical form or an
+// error if the url was not Safe Browsing compatible.
+func ParseURL(url string) (*Canonical, error) {
+	return parseURL(url)
 }
 
 // GeneratePatterns from url
@@ -83,14 +83,36 @@ func GeneratePatterns(url string) ([]string, error) {
 	return generatePatterns(url)
 }
 
+// Default expansion limits of the Safe Browsing policy.
+const (
+	DefaultHostComponents = 5
+	DefaultPathComponents = 4
+)
+
+// LookupHosts returns the exact hostname of the url followed by its
+// suffixes of at most maxComponents components, longest first. A limit of
+// zero or less uses DefaultHostComponents. When publicSuffix is set the
+// suffixes stop at the registered domain instead of reaching the public
+// suffix, eg. "co.uk" is never returned for "a.example.co.uk".
+func LookupHosts(url string, maxComponents int, publicSuffix bool) ([]string, error) {
+	return generateLookupHosts(url, maxComponents, publicSuffix)
+}
+
+// LookupPaths returns the path-prefixes of the url: the root, up to
+// maxComponents-1 directories below it, the path, and the path with the
+// query. A limit of zero or less uses DefaultPathComponents.
+func LookupPaths(url string, maxComponents int) ([]string, error) {
+	return generateLookupPaths(url, maxComponents)
+}
+
 // generatePatterns returns all possible host-suffix and path-prefix patterns
 // for the input URL.
 func generatePatterns(url string) ([]string, error) {
-	hosts, err := generateLookupHosts(url)
+	hosts, err := generateLookupHosts(url, DefaultHostComponents, false)
 	if err != nil {
 		return nil, err
 	}
-	paths, err := generateLookupPaths(url)
+	paths, err := generateLookupPaths(url, DefaultPathComponents)
 	if err != nil {
 		return nil, err
 	}
@@ -189,27 +211,27 @@ func unescape(s string) string {
 
 // recursiveUnescape unescapes the string s recursively until it cannot be
 // unescaped anymore. It reports an error if the unescaping process seemed to
-// have no end.
-func recursiveUnescape(s string) (string, error) {
+// have no end. The depth is the number of rounds that changed s.
+func recursiveUnescape(s string) (string, int, error) {
 	const maxDepth = 1024
 	for i := 0; i < maxDepth; i++ {
 		t := unescape(s)
 		if t == s {
-			return s, nil
+			return s, i, nil
 		}
 		s = t
 	}
-	return "", errors.New("safebrowsing: unescaping is too recursive")
+	return "", maxDepth, errors.New("safebrowsing: unescaping is too recursive")
 }
 
 // normalizeEscape performs a recursive unescape and then escapes the string
 // exactly once. It reports an error if it was unable to unescape the string.
-func normalizeEscape(s string) (string, error) {
-	u, err := recursiveUnescape(s)
+func normalizeEscape(s string) (string, int, error) {
+	u, depth, err := recursiveUnescape(s)
 	if err != nil {
-		return "", err
+		return "", depth, err
 	}
-	return escape(u), nil
+	return escape(u), depth, nil
 }
 
 // getScheme splits the url into (scheme, path) where scheme is the protocol.
@@ -234,8 +256,8 @@ func getScheme(url string) (scheme, path string) {
 }
 
 // parseHost parses a string to get host by the stripping the
-// username, password, and port.
-func parseHost(url *url.URL, hostish string) (host string, err error) {
+// username, password, and port; the host flags of c are set.
+func parseHost(c *Canonical, hostish string) (host string, err error) {
 	i := strings.LastIndex(hostish, "@")
 	if i < 0 {
 		host = hostish
@@ -260,25 +282,29 @@ func parseHost(url *url.URL, hostish string) (host string, err error) {
 		if err != nil {
 			return "", err
 		}
-		//url.IDNA = true
+		c.IDNA = true
 	}
 
 	// Remove any superfluous '.' characters in the hostname.
+	dotted := host
 	host = dotsRegexp.ReplaceAllString(host, ".")
 	host = strings.Trim(host, ".")
+	c.DotsRemoved = host != dotted
 	// Canonicalize IP addresses.
-	if iphost := parseIPAddress(host); iphost != "" {
+	if iphost, enc := parseIPAddress(host); iphost != "" {
 		host = iphost
-		//url.IP = true
+		c.IP = true
+		c.IPEncoding = enc
 	} else {
 		host = strings.ToLower(host)
-		//url.WWW = strings.HasPrefix(host, "www.")
+		c.IP = net.ParseIP(strings.Trim(host, "[]")) != nil && strings.HasPrefix(host, "[")
+		c.WWW = strings.HasPrefix(host, "www.")
 	}
 	return host, nil
 }
 
 // parseURL parses urlStr as a url.URL and reports an error if not possible.
-func parseURL(urlStr string) (parsedURL *url.URL, err error) {
+func parseURL(urlStr string) (parsedURL *Canonical, err error) {
 	// For legacy reasons, this is a simplified version of the net/url logic.
 	//
 	// Few cases where net/url was not helpful:
@@ -290,7 +316,7 @@ func parseURL(urlStr string) (parsedURL *url.URL, err error) {
 	// 3. We strip off the fragment and the escaped query as they are not
 	// required for building patterns for Safe Browsing.
 
-	parsedURL = new(url.URL)
+	parsedURL = &Canonical{URL: new(url.URL)}
 	// Remove the URL fragment.
 	// Also, we decode and encode the URL.
 	// The '#' in a fragment is not friendly to that.
@@ -301,12 +327,15 @@ func parseURL(urlStr string) (parsedURL *url.URL, err error) {
 	rest = strings.Replace(rest, "\t", "", -1)
 	rest = strings.Replace(rest, "\r", "", -1)
 	rest = strings.Replace(rest, "\n", "", -1)
-	rest, err = normalizeEscape(rest)
+	rest, parsedURL.UnescapeDepth, err = normalizeEscape(rest)
 	if err != nil {
 		return nil, err
 	}
 	parsedURL.Scheme, rest = getScheme(rest)
+	// Keep an empty query as it is distinct, eg. "http://host/q?".
+	hasQuery := strings.Contains(rest, "?")
 	rest, parsedURL.RawQuery = split(rest, "?", true)
+	parsedURL.ForceQuery = hasQuery && parsedURL.RawQuery == ""
 
 	// Add HTTP as scheme if none.
 	var hostish string
@@ -331,14 +360,17 @@ func parseURL(urlStr string) (parsedURL *url.URL, err error) {
 	p := path.Clean(rest)
 	if p == "." {
 		p = "/"
-	} else if rest[len(rest)-1] == '/' && p[len(p)-1] != '/' {
+	} else if strings.HasSuffix(rest, "/") && !strings.HasSuffix(p, "/") {
 		p += "/"
 	}
 	parsedURL.Path = p
 	return parsedURL, nil
 }
 
-func parseIPAddress(iphostname string) string {
+// parseIPAddress returns the dotted decimal form of iphostname and the
+// obfuscated encodings it used, or "" if it is not an IPv4 address.
+func parseIPAddress(iphostname string) (string, IPEncoding) {
+	var enc IPEncoding
 	// The Windows resolver allows a 4-part dotted decimal IP address to have a
 	// space followed by any old rubbish, so long as the total length of the
 	// string doesn't get above 15 characters. So, "10.192.95.89 xy" is
@@ -349,27 +381,39 @@ func parseIPAddress(iphostname string) string {
 		match := trailingSpaceRegexp.FindString(iphostname)
 		if match != "" {
 			iphostname = strings.TrimSpace(match)
+			enc |= IPTrailing
 		}
 	}
 	if !possibleIPRegexp.MatchString(iphostname) {
-		return ""
+		return "", 0
 	}
 	parts := strings.Split(iphostname, ".")
-	if len(parts) > 4 {
-		return ""
+	switch {
+	case len(parts) > 4:
+		return "", 0
+	case len(parts) == 1:
+		enc |= IPDword
+	case len(parts) < 4:
+		enc |= IPShort
 	}
 	ss := make([]string, len(parts))
 	for i, n := range parts {
+		switch {
+		case len(n) > 1 && (n[1] == 'x' || n[1] == 'X'):
+			enc |= IPHex
+		case len(n) > 1 && n[0] == '0':
+			enc |= IPOctal
+		}
 		if i == len(parts)-1 {
 			ss[i] = canonicalNum(n, 5-len(parts))
 		} else {
 			ss[i] = canonicalNum(n, 1)
 		}
 		if ss[i] == "" {
-			return ""
+			return "", 0
 		}
 	}
-	return strings.Join(ss, ".")
+	return strings.Join(ss, "."), enc
 }
 
 // canonicalNum parses s as an integer and attempts to encode it as a '.'
@@ -423,9 +467,11 @@ func canonicalHost(urlStr string) (string, error) {
 }
 
 // generateLookupHosts returns a list of host-suffixes for the input URL.
-func generateLookupHosts(urlStr string) ([]string, error) {
+func generateLookupHosts(urlStr string, maxHostComponents int, publicSuffix bool) ([]string, error) {
 	// Safe Browsing policy asks to generate lookup hosts for the URL.
-	// Those are formed by the domain and also up to 4 hostnames suffixes.
+	// Those are formed by the exact hostname and also up to 4 hostnames
+	// formed by starting with the last 5 components and successively
+	// removing the leading component.
 	// The last component or sometimes the pair isn't examined alone,
 	// since it's the TLD or country code. The database for TLDs is here:
 	//	https://publicsuffix.org/list/
@@ -433,8 +479,11 @@ func generateLookupHosts(urlStr string) ([]string, error) {
 	// Note that we do not need to be clever about stopping at the "real" TLD.
 	// We just check a few extra components regardless. It's not significantly
 	// slower on the server side to check some extra hashes. Also the client
-	// does not need to keep a database of TLDs.
-	const maxHostComponents = 7
+	// does not need to keep a database of TLDs. Callers with their own
+	// blocklists can opt in to stopping at the public suffix.
+	if maxHostComponents <= 0 {
+		maxHostComponents = DefaultHostComponents
+	}
 
 	host, err := canonicalHost(urlStr)
 	if err != nil {
@@ -452,9 +501,18 @@ func generateLookupHosts(urlStr string) ([]string, error) {
 		numComponents = 1
 	}
 
+	var suffix string
+	if publicSuffix {
+		suffix, _ = publicsuffix.PublicSuffix(host)
+	}
+
 	hosts := []string{host}
 	for i := numComponents; i < len(hostComponents)-1; i++ {
-		hosts = append(hosts, strings.Join(hostComponents[i:], "."))
+		h := strings.Join(hostComponents[i:], ".")
+		if len(h) <= len(suffix) {
+			break
+		}
+		hosts = append(hosts, h)
 	}
 	return hosts, nil
 }
@@ -470,8 +528,10 @@ func generateLookupHosts(urlStr string) ([]string, error) {
 // }
 
 // generateLookupPaths returns a list path-prefixes for the input URL.
-func generateLookupPaths(urlStr string) ([]string, error) {
-	const maxPathComponents = 4
+func generateLookupPaths(urlStr string, maxPathComponents int) ([]string, error) {
+	if maxPathComponents <= 0 {
+		maxPathComponents = DefaultPathComponents
+	}
 
 	parsedURL, err := parseURL(urlStr)
 	if err != nil {
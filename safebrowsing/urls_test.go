@@ -0,0 +1,200 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package safebrowsing
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The vectors below are from the Safe Browsing developer documentation:
+//	https://developers.google.com/safe-browsing/v4/urls-hashing
+
+func TestParseURL(t *testing.T) {
+
+	for _, v := range []struct {
+		URL, Canonical string
+	}{
+		{"http://host/%25%32%35", "http://host/%25"},
+		{"http://host/%25%32%35%25%32%35", "http://host/%25%25"},
+		{"http://host/%2525252525252525", "http://host/%25"},
+		{"http://host/asdf%25%32%35asd", "http://host/asdf%25asd"},
+		{"http://host/%%%25%32%35asd%%", "http://host/%25%25%25asd%25%25"},
+		{"http://www.google.com/", "http://www.google.com/"},
+		{"http://%31%36%38%2e%31%38%38%2e%39%39%2e%32%36/%2E%73%65%63%75%72%65/%77%77%77%2E%65%62%61%79%2E%63%6F%6D/", "http://168.188.99.26/.secure/www.ebay.com/"},
+		{"http://195.127.0.11/uploads/%20%20%20%20/.verify/.eBaysecure=updateuserdataxplimnbqmn-xplmvalidateinfoswqpcmlx=hgplmcx/", "http://195.127.0.11/uploads/%20%20%20%20/.verify/.eBaysecure=updateuserdataxplimnbqmn-xplmvalidateinfoswqpcmlx=hgplmcx/"},
+		{"http://host%23.com/%257Ea%2521b%2540c%2523d%2524e%25f%255E00%252611%252A22%252833%252944_55%252B", "http://host%23.com/~a!b@c%23d$e%25f^00&11*22(33)44_55+"},
+		{"http://3279880203/blah", "http://195.127.0.11/blah"},
+		{"http://www.google.com/blah/..", "http://www.google.com/"},
+		{"www.google.com/", "http://www.google.com/"},
+		{"www.google.com", "http://www.google.com/"},
+		{"http://www.evil.com/blah#frag", "http://www.evil.com/blah"},
+		{"http://www.GOOgle.com/", "http://www.google.com/"},
+		{"http://www.google.com.../", "http://www.google.com/"},
+		{"http://www.google.com/foo\tbar\rbaz\n2", "http://www.google.com/foobarbaz2"},
+		{"http://www.google.com/q?", "http://www.google.com/q?"},
+		{"http://www.google.com/q?r?", "http://www.google.com/q?r?"},
+		{"http://www.google.com/q?r?s", "http://www.google.com/q?r?s"},
+		{"http://evil.com/foo#bar#baz", "http://evil.com/foo"},
+		{"http://evil.com/foo;", "http://evil.com/foo;"},
+		{"http://evil.com/foo?bar;", "http://evil.com/foo?bar;"},
+		{"http://\x01\x80.com/", "http://%01%80.com/"},
+		{"http://notrailingslash.com", "http://notrailingslash.com/"},
+		{"http://www.gotaport.com:1234/", "http://www.gotaport.com/"},
+		{"  http://www.google.com/  ", "http://www.google.com/"},
+		{"http:// leadingspace.com/", "http://%20leadingspace.com/"},
+		{"http://%20leadingspace.com/", "http://%20leadingspace.com/"},
+		{"%20leadingspace.com/", "http://%20leadingspace.com/"},
+		{"https://www.securesite.com/", "https://www.securesite.com/"},
+		{"http://host.com/ab%23cd", "http://host.com/ab%23cd"},
+		{"http://host.com//twoslashes?more//slashes", "http://host.com/twoslashes?more//slashes"},
+	} {
+		c, err := parseURL(v.URL)
+		if err != nil {
+			t.Errorf("%q: %v", v.URL, err)
+			continue
+		}
+		if c.String() != v.Canonical {
+			t.Errorf("%q: got %q, want %q", v.URL, c.String(), v.Canonical)
+		}
+	}
+
+	for _, s := range []string{"", "http://", "http:///path", "http:host", "http://[::1/"} {
+		if _, err := parseURL(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestGenerateLookupHosts(t *testing.T) {
+
+	for _, v := range []struct {
+		URL   string
+		Hosts []string
+	}{
+		{"http://a.b.c/1/2.html?param=1", []string{"a.b.c", "b.c"}},
+		{"http://a.b.c.d.e.f.g/1.html", []string{"a.b.c.d.e.f.g", "c.d.e.f.g", "d.e.f.g", "e.f.g", "f.g"}},
+		{"http://1.2.3.4/1/", []string{"1.2.3.4"}},
+		{"http://[2001:db8::1]/", []string{"[2001:db8::1]"}},
+		{"http://example.co.uk/", []string{"example.co.uk", "co.uk"}},
+		{"http://localhost/", []string{"localhost"}},
+	} {
+		hosts, err := generateLookupHosts(v.URL, DefaultHostComponents, false)
+		if err != nil || !reflect.DeepEqual(hosts, v.Hosts) {
+			t.Errorf("%q: got %q, want %q (%v)", v.URL, hosts, v.Hosts, err)
+		}
+	}
+}
+
+func TestGenerateLookupPaths(t *testing.T) {
+
+	for _, v := range []struct {
+		URL   string
+		Paths []string
+	}{
+		{"http://a.b.c/1/2.html?param=1", []string{"/", "/1/", "/1/2.html", "/1/2.html?param=1"}},
+		{"http://a.b.c.d.e.f.g/1.html", []string{"/", "/1.html"}},
+		{"http://1.2.3.4/1/", []string{"/", "/1/"}},
+		{"http://a.b.c/", []string{"/"}},
+		{"http://a.b.c/1/2/3/4/5/6.html", []string{"/", "/1/", "/1/2/", "/1/2/3/", "/1/2/3/4/5/6.html"}},
+		{"http://a.b.c/q?", []string{"/", "/q"}},
+	} {
+		paths, err := generateLookupPaths(v.URL, DefaultPathComponents)
+		if err != nil || !reflect.DeepEqual(paths, v.Paths) {
+			t.Errorf("%q: got %q, want %q (%v)", v.URL, paths, v.Paths, err)
+		}
+	}
+}
+
+func TestGeneratePatterns(t *testing.T) {
+
+	for _, v := range []struct {
+		URL      string
+		Patterns []string
+	}{
+		{"http://a.b.c/1/2.html?param=1", []string{
+			"a.b.c/", "a.b.c/1/", "a.b.c/1/2.html", "a.b.c/1/2.html?param=1",
+			"b.c/", "b.c/1/", "b.c/1/2.html", "b.c/1/2.html?param=1",
+		}},
+		{"http://a.b.c.d.e.f.g/1.html", []string{
+			"a.b.c.d.e.f.g/", "a.b.c.d.e.f.g/1.html",
+			"c.d.e.f.g/", "c.d.e.f.g/1.html",
+			"d.e.f.g/", "d.e.f.g/1.html",
+			"e.f.g/", "e.f.g/1.html",
+			"f.g/", "f.g/1.html",
+		}},
+		{"http://1.2.3.4/1/", []string{"1.2.3.4/", "1.2.3.4/1/"}},
+	} {
+		patterns, err := GeneratePatterns(v.URL)
+		if err != nil || !reflect.DeepEqual(patterns, v.Patterns) {
+			t.Errorf("%q: got %q, want %q (%v)", v.URL, patterns, v.Patterns, err)
+		}
+	}
+
+	if _, err := GeneratePatterns("http://"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestLookupLimits(t *testing.T) {
+
+	for _, v := range []struct {
+		URL          string
+		Max          int
+		PublicSuffix bool
+		Hosts        []string
+	}{
+		{"http://a.b.c.d.example.co.uk/", 0, false, []string{"a.b.c.d.example.co.uk", "c.d.example.co.uk", "d.example.co.uk", "example.co.uk", "co.uk"}},
+		{"http://a.b.c.d.example.co.uk/", 0, true, []string{"a.b.c.d.example.co.uk", "c.d.example.co.uk", "d.example.co.uk", "example.co.uk"}},
+		{"http://a.b.c.d.example.co.uk/", 3, true, []string{"a.b.c.d.example.co.uk", "example.co.uk"}},
+		{"http://a.b.c.d.example.co.uk/", 10, false, []string{"a.b.c.d.example.co.uk", "b.c.d.example.co.uk", "c.d.example.co.uk", "d.example.co.uk", "example.co.uk", "co.uk"}},
+		{"http://example.co.uk/", 0, true, []string{"example.co.uk"}},
+		{"http://co.uk/", 0, true, []string{"co.uk"}},
+		{"http://1.2.3.4/", 2, true, []string{"1.2.3.4"}},
+	} {
+		hosts, err := LookupHosts(v.URL, v.Max, v.PublicSuffix)
+		if err != nil || !reflect.DeepEqual(hosts, v.Hosts) {
+			t.Errorf("%q %d %v: got %q, want %q (%v)", v.URL, v.Max, v.PublicSuffix, hosts, v.Hosts, err)
+		}
+	}
+
+	for _, v := range []struct {
+		URL   string
+		Max   int
+		Paths []string
+	}{
+		{"http://a.b.c/1/2/3/4/5/6.html?q", 0, []string{"/", "/1/", "/1/2/", "/1/2/3/", "/1/2/3/4/5/6.html", "/1/2/3/4/5/6.html?q"}},
+		{"http://a.b.c/1/2/3/4/5/6.html?q", 2, []string{"/", "/1/", "/1/2/3/4/5/6.html", "/1/2/3/4/5/6.html?q"}},
+		{"http://a.b.c/1/2/3/4/5/6.html", 1, []string{"/", "/1/2/3/4/5/6.html"}},
+		{"http://a.b.c/1/2/3/4/5/6.html", 10, []string{"/", "/1/", "/1/2/", "/1/2/3/", "/1/2/3/4/", "/1/2/3/4/5/", "/1/2/3/4/5/6.html"}},
+	} {
+		paths, err := LookupPaths(v.URL, v.Max)
+		if err != nil || !reflect.DeepEqual(paths, v.Paths) {
+			t.Errorf("%q %d: got %q, want %q (%v)", v.URL, v.Max, paths, v.Paths, err)
+		}
+	}
+
+	if _, err := LookupHosts("http://", 0, false); err == nil {
+		t.Error("expected error")
+	}
+}
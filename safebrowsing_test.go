@@ -0,0 +1,117 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+	"github.com/zxdev/url/v2/safebrowsing"
+)
+
+// sameURL compares the exported fields
+func sameURL(a, b url.URL) bool {
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Port == b.Port &&
+		a.Path == b.Path && a.Page == b.Page && a.Query == b.Query &&
+		a.IP == b.IP && a.IDNA == b.IDNA
+}
+
+func TestSafeBrowsing(t *testing.T) {
+
+	for _, v := range []struct {
+		URL, Canonical string
+		Expect         url.URL
+	}{
+		{"http://0x7f.1/%7Ea/./b.html?y=2&x=1#f", "http://127.0.0.1/~a/b.html?y=2&x=1",
+			url.URL{Scheme: "http", Host: "127.0.0.1", Path: "~a", Page: "b.html", Query: "x=1&y=2", IP: true}},
+		{"HTTP://WWW.Example.COM:8080/a/b/", "http://www.example.com/a/b/",
+			url.URL{Scheme: "http", Host: "www.example.com", Path: "a/b/"}},
+		{"https://bücher.example", "https://xn--bcher-kva.example/",
+			url.URL{Scheme: "https", Host: "xn--bcher-kva.example", IDNA: true}},
+	} {
+		u, err := url.ParseSafeBrowsing(v.URL)
+		if err != nil || !sameURL(u, v.Expect) {
+			t.Errorf("%s: %+v %v", v.URL, u, err)
+			continue
+		}
+		c, err := url.ToSafeBrowsing(&u)
+		if err != nil || c.String() != v.Canonical {
+			t.Error(v.URL, c, err)
+			continue
+		}
+		if back := url.FromSafeBrowsing(c); back != u {
+			t.Errorf("%s: round trip %+v", v.URL, back)
+		}
+	}
+
+	// the core parser keeps the port and drops the scheme from String
+	var u url.URL
+	u.Parse("[2001:db8::1]:8080/path")
+	c, err := url.ToSafeBrowsing(&u)
+	if err != nil || c.String() != "http://[2001:db8::1]/path" {
+		t.Fatal(c, err)
+	}
+	if back := url.FromSafeBrowsing(c); back.String() != "2001:db8::1/path" || !back.IP {
+		t.Errorf("%+v", back)
+	}
+
+	// an empty query is kept as in the safebrowsing canonical form
+	u, err = url.ParseSafeBrowsing("http://example.com/path?")
+	if err != nil || u.Query != "" {
+		t.Fatal(u, err)
+	}
+	if c, err = url.ToSafeBrowsing(&u); err != nil || c.String() != "http://example.com/path?" {
+		t.Fatal(c, err)
+	}
+
+	// both parsers give the same keys and patterns for an unsorted query
+	const unsorted = "http://example.com/p/q.html?b=2&a=1&c=%7e"
+	var core url.URL
+	core.Parse(unsorted)
+	sb, err := url.ParseSafeBrowsing(unsorted)
+	if err != nil || core.Query != sb.Query {
+		t.Fatal(core.Query, sb.Query, err)
+	}
+	k1, _ := url.FPHex64(&core, url.FullQuery)
+	k2, _ := url.FPHex64(&sb, url.FullQuery)
+	if k1 != k2 {
+		t.Fatal("full-query keys", k1, k2)
+	}
+	direct, _ := safebrowsing.ParseURL(unsorted)
+	for _, v := range []*url.URL{&core, &sb} {
+		c, err := url.ToSafeBrowsing(v)
+		if err != nil || c.String() != direct.String() || c.RawQuery != "b=2&a=1&c=~" {
+			t.Fatal(c, direct, err)
+		}
+		p1, _ := safebrowsing.GeneratePatterns(c.String())
+		p2, _ := safebrowsing.GeneratePatterns(unsorted)
+		if strings.Join(p1, " ") != strings.Join(p2, " ") {
+			t.Fatal(p1, p2)
+		}
+	}
+
+	if _, err := url.ParseSafeBrowsing("http:no-slashes"); err == nil {
+		t.Error("expected error")
+	}
+}
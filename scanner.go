@@ -0,0 +1,221 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+
+	url.URL streaming scanner with line numbers, error modes and statistics;
+	blank lines are skipped and lines over MaxLine are discarded as invalid
+
+	s := url.NewScanner(r)
+	s.StopOnError = true
+	for s.Scan() {
+		u := s.URL()
+		fmt.Println(s.Line(), u.Host)
+	}
+	if err := s.Err(); err != nil {
+		var perr *url.ParseError
+		errors.As(err, &perr) // perr.Line, perr.Text
+	}
+	fmt.Printf("%+v\n", s.Stats)
+
+*/
+
+// DefaultMaxLine is the longest line a Scanner accepts by default
+const DefaultMaxLine = 1 << 16
+
+// scanner errors reported with the line in a *ParseError
+var (
+	ErrInvalid     = errors.New("url: invalid url")
+	ErrLineTooLong = errors.New("url: line too long")
+)
+
+// ParseError reports the line that failed; Text is empty for a long line
+// and Record is the record number from a RecordScanner
+type ParseError struct {
+	Line   int
+	Record int
+	Text   string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Record > 0 {
+		return fmt.Sprintf("%v: line %d: record %d: %q", e.Err, e.Line, e.Record, e.Text)
+	}
+	return fmt.Sprintf("%v: line %d: %q", e.Err, e.Line, e.Text)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Stats counts the non-blank lines read by a Scanner
+type Stats struct {
+	Total, Valid, Invalid int
+	IP, IDNA              int
+}
+
+// Scanner reads urls line by line from an io.Reader; invalid lines are
+// skipped and counted unless StopOnError is set. MaxLine and StopOnError
+// must be set before the first Scan
+type Scanner struct {
+	MaxLine     int  // longest line accepted; default DefaultMaxLine
+	StopOnError bool // stop at the first invalid line; default skip
+	Stats       Stats
+
+	r          io.Reader
+	sc         *bufio.Scanner
+	u          URL
+	text       string
+	line       int
+	err        error
+	skip, long bool // discarding a long line; long line consumed
+}
+
+// NewScanner returns a Scanner reading from r
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: r}
+}
+
+// Scan advances to the next valid url, returning false at the end of
+// the input, on a read error, or on an invalid line with StopOnError
+func (s *Scanner) Scan() bool {
+
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		text, long, ok := s.read()
+		if !ok {
+			return false
+		}
+
+		var err error
+		switch {
+		case long:
+			err = ErrLineTooLong
+		case len(strings.TrimSpace(text)) == 0:
+			continue
+		case !s.u.Parse(text):
+			err = ErrInvalid
+		}
+
+		s.Stats.Total++
+		if err != nil {
+			s.Stats.Invalid++
+			if s.StopOnError {
+				s.err = &ParseError{Line: s.line, Text: text, Err: err}
+				return false
+			}
+			continue
+		}
+
+		s.Stats.Valid++
+		if s.u.IP {
+			s.Stats.IP++
+		}
+		if s.u.IDNA {
+			s.Stats.IDNA++
+		}
+		s.text = text
+		return true
+	}
+}
+
+// read returns the next raw line; a line over MaxLine is returned
+// empty and flagged as long
+func (s *Scanner) read() (text string, long, ok bool) {
+
+	if s.sc == nil {
+		if s.MaxLine <= 0 {
+			s.MaxLine = DefaultMaxLine
+		}
+		s.sc = bufio.NewScanner(s.r)
+		s.sc.Buffer(make([]byte, 0, 4096), s.MaxLine+1)
+		s.sc.Split(s.split)
+	}
+
+	if !s.sc.Scan() {
+		s.err = s.sc.Err()
+		return "", false, false
+	}
+
+	s.line++
+	long, s.long = s.long, false
+	if long {
+		return "", true, true
+	}
+	return s.sc.Text(), false, true
+}
+
+// URL returns the url parsed by the last successful Scan
+func (s *Scanner) URL() URL { return s.u }
+
+// Text returns the line of the last successful Scan
+func (s *Scanner) Text() string { return s.text }
+
+// Line returns the 1-based line number of the last line read
+func (s *Scanner) Line() int { return s.line }
+
+// Err returns the first read error or *ParseError; nil at end of input
+func (s *Scanner) Err() error { return s.err }
+
+// split is bufio.ScanLines that discards lines over MaxLine rather
+// than failing with bufio.ErrTooLong; a discarded line is flagged
+// with s.long and returned as an empty token
+func (s *Scanner) split(data []byte, atEOF bool) (int, []byte, error) {
+
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		if s.skip || i > s.MaxLine {
+			s.skip, s.long = false, true
+			return i + 1, data[:0], nil
+		}
+		return i + 1, bytes.TrimSuffix(data[:i], []byte("\r")), nil
+	}
+
+	switch {
+	case atEOF && s.skip:
+		s.skip, s.long = false, true
+		return len(data), data[:0], nil
+	case atEOF && len(data) > 0:
+		if len(data) > s.MaxLine {
+			s.long = true
+			return len(data), data[:0], nil
+		}
+		return len(data), bytes.TrimSuffix(data, []byte("\r")), nil
+	case len(data) > s.MaxLine:
+		s.skip = true
+		return len(data), nil, nil
+	}
+
+	return 0, nil, nil
+}
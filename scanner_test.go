@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestScanner(t *testing.T) {
+
+	long := "example.com/" + strings.Repeat("a", 100)
+	input := strings.Join([]string{
+		"example.com/path",
+		"",
+		"bad",
+		long,
+		"1.2.3.4",
+		"bücher.example\r",
+		long + "\r", // last line without newline
+	}, "\n")
+
+	s := url.NewScanner(strings.NewReader(input))
+	s.MaxLine = 64
+	var hosts []string
+	var lines []int
+	for s.Scan() {
+		u := s.URL()
+		hosts = append(hosts, u.Host)
+		lines = append(lines, s.Line())
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if strings.Join(hosts, " ") != "example.com 1.2.3.4 xn--bcher-kva.example" {
+		t.Fatal(hosts)
+	}
+	if lines[0] != 1 || lines[1] != 5 || lines[2] != 6 {
+		t.Fatal(lines)
+	}
+	if s.Stats != (url.Stats{Total: 6, Valid: 3, Invalid: 3, IP: 1, IDNA: 1}) {
+		t.Fatalf("%+v", s.Stats)
+	}
+
+	// stop at the first invalid line; a long line before the newline
+	// has been buffered is still reported on its own line
+	for _, v := range []struct {
+		input string
+		line  int
+		text  string
+		err   error
+	}{
+		{"a.com\n\nbad\nb.com\n", 3, "bad", url.ErrInvalid},
+		{"a.com\n" + long + "\nb.com\n", 2, "", url.ErrLineTooLong},
+		{"a.com\n" + long, 2, "", url.ErrLineTooLong},
+	} {
+		s = url.NewScanner(iotest.OneByteReader(strings.NewReader(v.input)))
+		s.MaxLine = 64
+		s.StopOnError = true
+		var n int
+		for s.Scan() {
+			n++
+		}
+		var perr *url.ParseError
+		if n != 1 || !errors.As(s.Err(), &perr) || !errors.Is(s.Err(), v.err) ||
+			perr.Line != v.line || perr.Text != v.text || s.Scan() {
+			t.Fatal(v.input, n, s.Err())
+		}
+	}
+
+	// read errors are reported
+	s = url.NewScanner(iotest.TimeoutReader(strings.NewReader("a.com\nb.com\n")))
+	for s.Scan() {
+	}
+	if !errors.Is(s.Err(), iotest.ErrTimeout) {
+		t.Fatal(s.Err())
+	}
+
+	// Parser skips invalid lines
+	var u url.URL
+	var n int
+	next := url.Parser(strings.NewReader("a.com\nbad\nb.com\n"))
+	for next(&u) {
+		n++
+	}
+	if n != 2 || u.Host != "b.com" {
+		t.Fatal(n, u)
+	}
+}
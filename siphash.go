@@ -0,0 +1,101 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// sipHash is a SipHash-2-4 hash.Hash64 that buffers the writes
+// until the sum is requested; fingerprint material is short
+type sipHash struct {
+	k0, k1 uint64
+	b      []byte
+}
+
+// NewSipHash returns a SipHash-2-4 hash.Hash64 keyed with the 128-bit key
+func NewSipHash(key [16]byte) hash.Hash64 {
+	return &sipHash{
+		k0: binary.LittleEndian.Uint64(key[:8]),
+		k1: binary.LittleEndian.Uint64(key[8:]),
+	}
+}
+
+func (s *sipHash) Write(p []byte) (int, error) { s.b = append(s.b, p...); return len(p), nil }
+func (s *sipHash) Reset()                      { s.b = s.b[:0] }
+func (s *sipHash) Size() int                   { return 8 }
+func (s *sipHash) BlockSize() int              { return 8 }
+func (s *sipHash) Sum(b []byte) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], s.Sum64())
+	return append(b, buf[:]...)
+}
+
+// Sum64 computes SipHash-2-4 over the buffered input
+func (s *sipHash) Sum64() uint64 {
+
+	v0 := s.k0 ^ 0x736f6d6570736575
+	v1 := s.k1 ^ 0x646f72616e646f6d
+	v2 := s.k0 ^ 0x6c7967656e657261
+	v3 := s.k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13) ^ v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16) ^ v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21) ^ v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17) ^ v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	p := s.b
+	for ; len(p) >= 8; p = p[8:] {
+		m := binary.LittleEndian.Uint64(p)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+
+	var tail [8]byte
+	copy(tail[:], p)
+	m := binary.LittleEndian.Uint64(tail[:]) | uint64(len(s.b))<<56
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
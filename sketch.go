@@ -0,0 +1,535 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/zxdev/xxhash/v2"
+)
+
+/*
+
+	url.URL probabilistic sketches over the xxhash64
+	fingerprint kinds so counts line up with FPUint64 keys
+
+	h := url.NewHLL(url.Apex, 14)  // distinct apex count
+	c := url.NewCMS(url.Host, 0, 0) // per host frequency
+	t := url.NewTopK(url.Host, 10)  // heavy hitter hosts
+
+	shards merge with Merge and travel as MarshalBinary
+
+*/
+
+// ErrSketch is returned when sketches of different shapes are
+// merged or a serialized sketch can not be decoded
+var ErrSketch = errors.New("url: sketch mismatch")
+
+const (
+	sketchVersion = 1
+	tagHLL        = 'h'
+	tagCMS        = 'c'
+	tagTopK       = 't'
+)
+
+// HLL is a HyperLogLog distinct counter over FPUint64 fingerprints
+type HLL struct {
+	kind Kind
+	p    uint8
+	reg  []uint8
+}
+
+// NewHLL returns a HyperLogLog for the kind with 2^p registers; p is
+// bound to 4..18 and 0 selects 14 for a ~0.8% standard error
+func NewHLL(kind Kind, p uint8) *HLL {
+
+	switch {
+	case p == 0:
+		p = 14
+	case p < 4:
+		p = 4
+	case p > 18:
+		p = 18
+	}
+
+	return &HLL{kind: kind, p: p, reg: make([]uint8, 1<<p)}
+}
+
+// Add the kind fingerprint of u; reports false when u has no such key
+func (h *HLL) Add(u *URL) bool {
+	key, err := FPUint64(u, h.kind)
+	if err == nil {
+		h.AddUint64(key)
+	}
+	return err == nil
+}
+
+// AddUint64 adds a precomputed FPUint64 fingerprint
+func (h *HLL) AddUint64(key uint64) {
+	idx := key >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(key<<h.p|1<<(h.p-1))) + 1
+	if rank > h.reg[idx] {
+		h.reg[idx] = rank
+	}
+}
+
+// Count estimates the number of distinct keys added
+func (h *HLL) Count() uint64 {
+
+	m := float64(len(h.reg))
+	var sum float64
+	var zeros int
+	for _, r := range h.reg {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	var alpha float64
+	switch len(h.reg) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 { // small range; linear counting
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
+
+// Merge folds o into h; both must share kind and precision
+func (h *HLL) Merge(o *HLL) error {
+	if o == nil || h.kind != o.kind || h.p != o.p {
+		return ErrSketch
+	}
+	for i, r := range o.reg {
+		if r > h.reg[i] {
+			h.reg[i] = r
+		}
+	}
+	return nil
+}
+
+// Reset clears the registers for the next window
+func (h *HLL) Reset() {
+	for i := range h.reg {
+		h.reg[i] = 0
+	}
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (h *HLL) MarshalBinary() ([]byte, error) {
+	b := []byte{tagHLL, sketchVersion}
+	b = appendUvarint(b, uint64(h.kind))
+	b = append(b, h.p)
+	return append(b, h.reg...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (h *HLL) UnmarshalBinary(data []byte) error {
+
+	d := sketchDecoder{b: data}
+	if !d.header(tagHLL) {
+		return ErrSketch
+	}
+	kind := Kind(d.uvarint())
+	p := d.byte()
+	if d.err || p < 4 || p > 18 || len(d.b) != 1<<p {
+		return ErrSketch
+	}
+
+	h.kind, h.p = kind, p
+	h.reg = append(h.reg[:0], d.b...)
+
+	return nil
+}
+
+// CMS is a count-min sketch estimating per key frequency over
+// FPUint64 fingerprints; estimates never undercount
+type CMS struct {
+	kind         Kind
+	width, depth uint32
+	total        uint64
+	count        []uint64
+}
+
+// NewCMS returns a count-min sketch for the kind; error is about
+// e/width of the total with probability 1-e^-depth and zero values
+// select 2048x5; depth is bound to 32 and width*depth to a uint32
+func NewCMS(kind Kind, width, depth uint32) *CMS {
+
+	if width == 0 {
+		width = 2048
+	}
+	switch {
+	case depth == 0:
+		depth = 5
+	case depth > 32:
+		depth = 32
+	}
+	if width > math.MaxUint32/depth {
+		width = math.MaxUint32 / depth
+	}
+
+	return &CMS{kind: kind, width: width, depth: depth, count: make([]uint64, width*depth)}
+}
+
+// cell returns the counter offset of key in row
+func (c *CMS) cell(key uint64, row uint32) uint32 {
+	h1, h2 := uint32(key), uint32(key>>32)|1
+	return row*c.width + (h1+row*h2)%c.width
+}
+
+// Add counts the kind fingerprint of u once; reports false when u has no such key
+func (c *CMS) Add(u *URL) bool {
+	key, err := FPUint64(u, c.kind)
+	if err == nil {
+		c.AddUint64(key, 1)
+	}
+	return err == nil
+}
+
+// AddUint64 counts a precomputed FPUint64 fingerprint n times
+func (c *CMS) AddUint64(key, n uint64) {
+	for row := uint32(0); row < c.depth; row++ {
+		c.count[c.cell(key, row)] += n
+	}
+	c.total += n
+}
+
+// Estimate returns the frequency estimate for the kind fingerprint of u
+func (c *CMS) Estimate(u *URL) uint64 {
+	if key, err := FPUint64(u, c.kind); err == nil {
+		return c.EstimateUint64(key)
+	}
+	return 0
+}
+
+// EstimateUint64 returns the frequency estimate for a FPUint64 fingerprint
+func (c *CMS) EstimateUint64(key uint64) uint64 {
+	est := uint64(math.MaxUint64)
+	for row := uint32(0); row < c.depth; row++ {
+		if n := c.count[c.cell(key, row)]; n < est {
+			est = n
+		}
+	}
+	return est
+}
+
+// Total reports the sum of all counts added
+func (c *CMS) Total() uint64 { return c.total }
+
+// Merge folds o into c; both must share kind, width and depth
+func (c *CMS) Merge(o *CMS) error {
+	if o == nil || c.kind != o.kind || c.width != o.width || c.depth != o.depth {
+		return ErrSketch
+	}
+	for i, n := range o.count {
+		c.count[i] += n
+	}
+	c.total += o.total
+	return nil
+}
+
+// Reset clears the counters for the next window
+func (c *CMS) Reset() {
+	for i := range c.count {
+		c.count[i] = 0
+	}
+	c.total = 0
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (c *CMS) MarshalBinary() ([]byte, error) {
+	b := []byte{tagCMS, sketchVersion}
+	b = appendUvarint(b, uint64(c.kind))
+	b = appendUvarint(b, uint64(c.width))
+	b = appendUvarint(b, uint64(c.depth))
+	b = appendUvarint(b, c.total)
+	for _, n := range c.count {
+		b = appendUvarint(b, n)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (c *CMS) UnmarshalBinary(data []byte) error {
+
+	d := sketchDecoder{b: data}
+	if !d.header(tagCMS) {
+		return ErrSketch
+	}
+	kind := Kind(d.uvarint())
+	width, depth := d.uvarint(), d.uvarint()
+	total := d.uvarint()
+	if d.err || width == 0 || depth == 0 || width > math.MaxUint32 || depth > math.MaxUint32 ||
+		width > math.MaxUint32/depth || width > uint64(len(d.b))/depth {
+		return ErrSketch // each counter takes at least one byte
+	}
+
+	count := make([]uint64, width*depth)
+	for i := range count {
+		count[i] = d.uvarint()
+	}
+	if d.err || len(d.b) != 0 {
+		return ErrSketch
+	}
+
+	c.kind, c.width, c.depth = kind, uint32(width), uint32(depth)
+	c.total, c.count = total, count
+
+	return nil
+}
+
+// HeavyHitter is a TopK entry
+type HeavyHitter struct {
+	Key   string
+	Count uint64
+}
+
+// TopK tracks the k most frequent keys of a kind; the key is the
+// material FPUint64 hashes, eg. the apex or host name
+type TopK struct {
+	k   int
+	cms *CMS
+	top map[string]uint64
+}
+
+// maxTopK bounds k so the tracked keys fit in memory
+const maxTopK = 1 << 20
+
+// NewTopK returns a heavy hitter tracker for the kind backed by a
+// default sized count-min sketch; k of 0 selects 10 and k is bound to 2^20
+func NewTopK(kind Kind, k int) *TopK {
+	switch {
+	case k <= 0:
+		k = 10
+	case k > maxTopK:
+		k = maxTopK
+	}
+	return &TopK{k: k, cms: NewCMS(kind, 0, 0), top: make(map[string]uint64, k+1)}
+}
+
+// Add counts the kind key of u once; reports false when u has no such key
+func (t *TopK) Add(u *URL) bool {
+	key, err := source(u, t.cms.kind)
+	if err == nil {
+		fp := xxhash.SSum(key)
+		t.cms.AddUint64(fp, 1)
+		t.offer(key, t.cms.EstimateUint64(fp))
+	}
+	return err == nil
+}
+
+// offer places key in the top set when it outranks the current minimum
+func (t *TopK) offer(key string, n uint64) {
+
+	if _, ok := t.top[key]; ok || len(t.top) < t.k {
+		t.top[key] = n
+		return
+	}
+
+	var low string
+	var minN uint64 = math.MaxUint64
+	for k, v := range t.top {
+		if v < minN || v == minN && k > low {
+			low, minN = k, v
+		}
+	}
+	if n > minN {
+		delete(t.top, low)
+		t.top[key] = n
+	}
+}
+
+// List returns the heavy hitters ordered by descending count
+func (t *TopK) List() []HeavyHitter {
+
+	list := make([]HeavyHitter, 0, len(t.top))
+	for k, n := range t.top {
+		list = append(list, HeavyHitter{Key: k, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count == list[j].Count {
+			return list[i].Key < list[j].Key
+		}
+		return list[i].Count > list[j].Count
+	})
+
+	return list
+}
+
+// Total reports the sum of all counts added
+func (t *TopK) Total() uint64 { return t.cms.total }
+
+// Merge folds o into t; candidates from both sides are re-estimated
+// against the merged sketch and the top k retained
+func (t *TopK) Merge(o *TopK) error {
+
+	if o == nil || t.k != o.k {
+		return ErrSketch
+	}
+	if err := t.cms.Merge(o.cms); err != nil {
+		return err
+	}
+
+	candidates := make([]string, 0, len(t.top)+len(o.top))
+	for k := range t.top {
+		candidates = append(candidates, k)
+	}
+	for k := range o.top {
+		if _, ok := t.top[k]; !ok {
+			candidates = append(candidates, k)
+		}
+	}
+
+	t.top = make(map[string]uint64, t.k+1)
+	for _, k := range candidates {
+		t.offer(k, t.cms.EstimateUint64(xxhash.SSum(k)))
+	}
+
+	return nil
+}
+
+// Reset clears the tracker for the next window
+func (t *TopK) Reset() {
+	t.cms.Reset()
+	t.top = make(map[string]uint64, t.k+1)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (t *TopK) MarshalBinary() ([]byte, error) {
+
+	cms, _ := t.cms.MarshalBinary()
+	b := []byte{tagTopK, sketchVersion}
+	b = appendUvarint(b, uint64(t.k))
+	b = appendUvarint(b, uint64(len(cms)))
+	b = append(b, cms...)
+	b = appendUvarint(b, uint64(len(t.top)))
+	for _, h := range t.List() {
+		b = appendUvarint(b, uint64(len(h.Key)))
+		b = append(b, h.Key...)
+		b = appendUvarint(b, h.Count)
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (t *TopK) UnmarshalBinary(data []byte) error {
+
+	d := sketchDecoder{b: data}
+	if !d.header(tagTopK) {
+		return ErrSketch
+	}
+	k := d.uvarint()
+	cms := new(CMS)
+	if d.err || k == 0 || k > maxTopK || cms.UnmarshalBinary(d.bytes()) != nil {
+		return ErrSketch
+	}
+
+	n := d.uvarint()
+	if d.err || n > k || n > uint64(len(d.b)) {
+		return ErrSketch // each entry takes at least two bytes
+	}
+	top := make(map[string]uint64, n+1)
+	for i := uint64(0); i < n; i++ {
+		key := string(d.bytes())
+		top[key] = d.uvarint()
+	}
+	if d.err || len(d.b) != 0 {
+		return ErrSketch
+	}
+
+	t.k, t.cms, t.top = int(k), cms, top
+
+	return nil
+}
+
+// appendUvarint appends the uvarint encoding of v to b
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// sketchDecoder reads the sketch wire format; err latches on
+// the first short or malformed read
+type sketchDecoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *sketchDecoder) header(tag byte) bool {
+	if len(d.b) < 2 || d.b[0] != tag || d.b[1] != sketchVersion {
+		return false
+	}
+	d.b = d.b[2:]
+	return true
+}
+
+func (d *sketchDecoder) byte() byte {
+	if d.err || len(d.b) == 0 {
+		d.err = true
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *sketchDecoder) uvarint() uint64 {
+	if d.err {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *sketchDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err || n > uint64(len(d.b)) {
+		d.err = true
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
@@ -0,0 +1,180 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestHLL(t *testing.T) {
+
+	a, b := url.NewHLL(url.Host, 14), url.NewHLL(url.Host, 14)
+	var u url.URL
+	for i := 0; i < 20000; i++ {
+		u.Parse(fmt.Sprintf("host%d.example.com/path", i))
+		if i < 12000 {
+			a.Add(&u)
+		}
+		if i >= 8000 {
+			b.Add(&u)
+		}
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := a.Count(); n < 19400 || n > 20600 {
+		t.Fatal("merged estimate out of range", n)
+	}
+
+	data, _ := a.MarshalBinary()
+	var c url.HLL
+	if err := c.UnmarshalBinary(data); err != nil || c.Count() != a.Count() {
+		t.Fatal("roundtrip", err, c.Count(), a.Count())
+	}
+
+	if err := a.Merge(url.NewHLL(url.Apex, 14)); err != url.ErrSketch {
+		t.Fatal("expected kind mismatch", err)
+	}
+
+	small := url.NewHLL(url.Apex, 0)
+	for _, v := range []string{"a.example.com", "b.example.com", "example.org", "www.example.net"} {
+		u.Parse(v)
+		small.Add(&u)
+	}
+	if n := small.Count(); n != 3 {
+		t.Fatal("expected 3 distinct apex", n)
+	}
+
+}
+
+func TestCMS(t *testing.T) {
+
+	var u url.URL
+	c := url.NewCMS(url.Host, 0, 0)
+	for i := 0; i < 5000; i++ {
+		u.Parse(fmt.Sprintf("host%d.example.com", i%500))
+		c.Add(&u)
+	}
+	u.Parse("host7.example.com/any/page.html")
+	if n := c.Estimate(&u); n < 10 || n > 15 {
+		t.Fatal("estimate out of range", n)
+	}
+
+	data, _ := c.MarshalBinary()
+	var d url.CMS
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Merge(c); err != nil || d.Total() != 10000 || d.Estimate(&u) != 2*c.Estimate(&u) {
+		t.Fatal("merge", err, d.Total())
+	}
+
+	// corrupt dimensions whose product wraps or exceeds the data
+	uvarint := func(b []byte, v uint64) []byte {
+		for ; v >= 0x80; v >>= 7 {
+			b = append(b, byte(v)|0x80)
+		}
+		return append(b, byte(v))
+	}
+	for _, v := range [][2]uint64{{1 << 33, 1 << 31}, {1 << 32, 1}, {1 << 20, 1 << 20}, {3, 0}, {100, 5}} {
+		b := append([]byte(nil), data[:2]...)
+		b = uvarint(b, uint64(url.Host))
+		b = uvarint(b, v[0])
+		b = uvarint(b, v[1])
+		b = uvarint(b, 1)
+		b = append(b, make([]byte, 64)...)
+		if err := d.UnmarshalBinary(b); err != url.ErrSketch {
+			t.Fatal("corrupt", v, err)
+		}
+	}
+	for i := range data { // truncated encodings never panic
+		d.UnmarshalBinary(data[:i])
+	}
+
+}
+
+func TestTopK(t *testing.T) {
+
+	var u url.URL
+	shard := func(heavy string, n int) *url.TopK {
+		top := url.NewTopK(url.Host, 3)
+		for i := 0; i < 2000; i++ {
+			u.Parse(fmt.Sprintf("noise%d.example.com", i))
+			top.Add(&u)
+		}
+		for i := 0; i < n; i++ {
+			u.Parse(heavy + "/path")
+			top.Add(&u)
+			u.Parse("shared.example.com")
+			top.Add(&u)
+		}
+		return top
+	}
+
+	a, b := shard("a.example.com", 100), shard("b.example.com", 80)
+	data, _ := b.MarshalBinary()
+	b = new(url.TopK)
+	if err := b.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// hostile k values and truncated encodings are rejected
+	_, n := binary.Uvarint(data[2:])
+	hostile := func(k uint64) []byte {
+		blob := make([]byte, 2+binary.MaxVarintLen64)
+		copy(blob, data[:2])
+		blob = blob[:2+binary.PutUvarint(blob[2:], k)]
+		return append(blob, data[2+n:]...)
+	}
+	for _, k := range []uint64{0, 1<<20 + 1, math.MaxInt64, math.MaxUint64} {
+		var hk url.TopK
+		if err := hk.UnmarshalBinary(hostile(k)); err != url.ErrSketch {
+			t.Fatal("hostile k", k, err)
+		}
+	}
+	if err := new(url.TopK).UnmarshalBinary(hostile(1 << 20)); err != nil {
+		t.Fatal("maximum k", err)
+	}
+	for i := range data {
+		if err := new(url.TopK).UnmarshalBinary(data[:i]); err != url.ErrSketch {
+			t.Fatal("truncated", i, err)
+		}
+	}
+
+	list := a.List()
+	if len(list) != 3 || list[0].Key != "shared.example.com" ||
+		list[1].Key != "a.example.com" || list[2].Key != "b.example.com" {
+		t.Fatal("unexpected heavy hitters", list)
+	}
+	t.Log(list)
+
+}
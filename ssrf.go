@@ -0,0 +1,336 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/*
+
+	url.Policy ssrf safe fetching of user supplied urls; every connected
+	ip is re-checked by the net.Dialer Control hook at connect time so a
+	host that resolves to a public address and then rebinds to an internal
+	one is still refused, and redirects are validated by the same policy
+
+	p := url.NewPolicy()
+	p.DenyCIDR("203.0.113.0/24")
+	client := p.Client(10 * time.Second)
+	_, err := client.Get(target)
+	var deny *url.DenyError
+	if errors.As(err, &deny) {
+		fmt.Println(deny.Stage, deny.Reason, deny.IP)
+	}
+
+*/
+
+// ErrDenied is wrapped by every DenyError
+var ErrDenied = errors.New("url: denied by policy")
+
+// DenyReason is why a Policy refused a url or an address
+type DenyReason int
+
+// DenyReason values
+const (
+	DenyPrivate   DenyReason = iota // IsPrivate; loopback, unspecified, RFC 1918/4193
+	DenyClass                       // non-public IPClass not in AllowClasses
+	DenyList                        // matched a Deny network
+	DenyScheme                      // scheme not in Schemes
+	DenyPort                        // port not in Ports
+	DenyAddress                     // unparsable host or address
+	DenyRedirects                   // more than MaxRedirects
+)
+
+var denyReasonNames = [...]string{"private address", "non-public address",
+	"deny list", "scheme", "port", "invalid address", "too many redirects"}
+
+func (r DenyReason) String() string {
+	if r < 0 || int(r) >= len(denyReasonNames) {
+		return fmt.Sprintf("reason(%d)", int(r))
+	}
+	return denyReasonNames[r]
+}
+
+// DenyError is the structured denial of a Policy; Stage is url, dial
+// or redirect and Target the url or the dialed ip:port
+type DenyError struct {
+	Stage  string
+	Reason DenyReason
+	Target string
+	IP     net.IP  // dial and ip url denials
+	Class  IPClass // of IP
+}
+
+func (e *DenyError) Error() string {
+	s := fmt.Sprintf("url: %s %s denied: %s", e.Stage, e.Target, e.Reason)
+	if e.IP != nil && (e.Reason == DenyPrivate || e.Reason == DenyClass) {
+		s += " (" + e.Class.String() + ")"
+	}
+	return s
+}
+
+// Unwrap returns ErrDenied
+func (e *DenyError) Unwrap() error { return ErrDenied }
+
+// Policy decides which schemes, ports and addresses may be fetched;
+// only IPPublic addresses are allowed unless the class is in AllowClasses
+// or the address is in an Allow network, and Deny networks always win
+type Policy struct {
+	AllowClasses []IPClass
+	Allow, Deny  []*net.IPNet
+	Schemes      []string // default http, https
+	Ports        []string // default any
+	MaxRedirects int      // default 10
+}
+
+// NewPolicy returns a policy allowing public http and https addresses
+func NewPolicy() *Policy {
+	return &Policy{Schemes: []string{"http", "https"}, MaxRedirects: 10}
+}
+
+// AllowCIDR adds networks or single addresses to the Allow list
+func (p *Policy) AllowCIDR(cidrs ...string) error {
+	nets, err := parseNets(cidrs)
+	p.Allow = append(p.Allow, nets...)
+	return err
+}
+
+// DenyCIDR adds networks or single addresses to the Deny list
+func (p *Policy) DenyCIDR(cidrs ...string) error {
+	nets, err := parseNets(cidrs)
+	p.Deny = append(p.Deny, nets...)
+	return err
+}
+
+func parseNets(cidrs []string) (nets []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nets, fmt.Errorf("url: invalid address %q", cidr)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nets, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// CheckIP reports whether the policy allows the address; nat64 and 6to4
+// addresses are also checked by their embedded ipv4 address and the
+// DenyError Stage and Target are left to the caller
+func (p *Policy) CheckIP(ip net.IP) error {
+
+	if ip == nil {
+		return &DenyError{Reason: DenyAddress, Class: IPInvalid}
+	}
+	class, v4 := ClassifyIP(ip), embeddedIPv4(ip)
+	contains := func(nets []*net.IPNet) bool {
+		for _, n := range nets {
+			if n.Contains(ip) || v4 != nil && n.Contains(v4) {
+				return true
+			}
+		}
+		return false
+	}
+	if contains(p.Deny) {
+		return &DenyError{Reason: DenyList, IP: ip, Class: class}
+	}
+	if contains(p.Allow) {
+		return nil
+	}
+	if class == IPPublic {
+		return nil
+	}
+	for _, c := range p.AllowClasses {
+		if c == class {
+			return nil
+		}
+	}
+
+	reason := DenyClass
+	if IsPrivate(ip) || v4 != nil && IsPrivate(v4) {
+		reason = DenyPrivate
+	}
+	return &DenyError{Reason: reason, IP: ip, Class: class}
+}
+
+// CheckURL validates the scheme, port and, for ip urls, the address
+// before any connection is made; host names are checked at dial time
+func (p *Policy) CheckURL(u *URL) error {
+
+	scheme := strings.ToLower(u.Scheme)
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+	port := u.Port
+	if len(port) == 0 {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	return p.check("url", u.String(), scheme, u.Host, port, u.IP)
+}
+
+// check is the shared url and redirect validation
+func (p *Policy) check(stage, target, scheme, host, port string, ip bool) error {
+
+	deny := func(err error) error {
+		if e, ok := err.(*DenyError); ok {
+			e.Stage, e.Target = stage, target
+		}
+		return err
+	}
+
+	switch {
+	case len(host) == 0:
+		return deny(&DenyError{Reason: DenyAddress})
+	case !p.allowScheme(scheme):
+		return deny(&DenyError{Reason: DenyScheme})
+	case !p.allowPort(port):
+		return deny(&DenyError{Reason: DenyPort})
+	case ip:
+		return deny(p.CheckIP(net.ParseIP(strings.Trim(host, "[]"))))
+	}
+
+	return nil
+}
+
+func (p *Policy) allowScheme(scheme string) bool {
+	if len(p.Schemes) == 0 {
+		return scheme == "http" || scheme == "https"
+	}
+	for _, s := range p.Schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowPort(port string) bool {
+	if len(p.Ports) == 0 {
+		return true
+	}
+	for _, v := range p.Ports {
+		if v == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Control is a net.Dialer Control hook that checks the resolved ip:port
+// of every connection attempt, after dns and before connect
+func (p *Policy) Control(network, address string, _ syscall.RawConn) error {
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return &DenyError{Stage: "dial", Target: address, Reason: DenyAddress}
+	}
+	if !p.allowPort(port) {
+		return &DenyError{Stage: "dial", Target: address, Reason: DenyPort}
+	}
+	if err = p.CheckIP(net.ParseIP(host)); err != nil {
+		err.(*DenyError).Stage, err.(*DenyError).Target = "dial", address
+	}
+	return err
+}
+
+// CheckRedirect is an http.Client CheckRedirect validating each
+// redirect target and limiting the number of redirects
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+
+	max := p.MaxRedirects
+	if max <= 0 {
+		max = 10
+	}
+	target := req.URL.String()
+	if len(via) >= max {
+		return &DenyError{Stage: "redirect", Target: target, Reason: DenyRedirects}
+	}
+
+	port := req.URL.Port()
+	if len(port) == 0 {
+		port = "80"
+		if strings.EqualFold(req.URL.Scheme, "https") {
+			port = "443"
+		}
+	}
+	host := req.URL.Hostname()
+	return p.check("redirect", target, strings.ToLower(req.URL.Scheme), host, port, net.ParseIP(host) != nil)
+}
+
+// Dialer returns a net.Dialer with the policy Control hook
+func (p *Policy) Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Control: p.Control}
+}
+
+// Transport returns a clone of http.DefaultTransport dialing through the
+// policy; proxies are disabled as they would connect on our behalf
+func (p *Policy) Transport(timeout time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = p.Dialer(timeout).DialContext
+	return t
+}
+
+// Client returns an http.Client with the policy Transport and
+// CheckRedirect; timeout bounds dialing and the whole request
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     p.Transport(timeout),
+		CheckRedirect: p.CheckRedirect,
+		Timeout:       timeout,
+	}
+}
+
+// Get validates the url with CheckURL and fetches it with the client
+func (p *Policy) Get(ctx context.Context, client *http.Client, u *URL) (*http.Response, error) {
+
+	if err := p.CheckURL(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Absolute(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestPolicyDial(t *testing.T) {
+
+	var requestURI string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, "http://10.0.0.1/admin", http.StatusFound)
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	deny := func(err error, stage string, reason url.DenyReason) *url.DenyError {
+		t.Helper()
+		var e *url.DenyError
+		if !errors.As(err, &e) || !errors.Is(err, url.ErrDenied) || e.Stage != stage || e.Reason != reason {
+			t.Fatal("expected", stage, reason, "got", err)
+		}
+		return e
+	}
+
+	// loopback is refused at connect time, also through a host name
+	p := url.NewPolicy()
+	_, err := p.Client(5 * time.Second).Get(srv.URL)
+	if e := deny(err, "dial", url.DenyPrivate); e.Class != url.IPLoopback || !e.IP.IsLoopback() {
+		t.Fatal(e)
+	}
+	_, err = p.Client(5 * time.Second).Get("http://localhost:" + port + "/")
+	deny(err, "dial", url.DenyPrivate)
+
+	// permitted when loopback is allowed
+	p.AllowClasses = []url.IPClass{url.IPLoopback}
+	client := p.Client(5 * time.Second)
+	res, err := client.Get(srv.URL)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatal(res, err)
+	}
+	res.Body.Close()
+
+	// redirects are validated by the same policy
+	_, err = client.Get(srv.URL + "/internal")
+	if e := deny(err, "redirect", url.DenyPrivate); e.Class != url.IPPrivate || e.Target != "http://10.0.0.1/admin" {
+		t.Fatal(e)
+	}
+	_, err = client.Get(srv.URL + "/ftp")
+	deny(err, "redirect", url.DenyScheme)
+	p.MaxRedirects = 3
+	_, err = client.Get(srv.URL + "/loop")
+	deny(err, "redirect", url.DenyRedirects)
+
+	// the deny list wins over allowed classes and networks
+	p = url.NewPolicy()
+	p.AllowCIDR("127.0.0.0/8")
+	p.DenyCIDR("127.0.0.1")
+	_, err = p.Client(5 * time.Second).Get(srv.URL)
+	deny(err, "dial", url.DenyList)
+
+	p = url.NewPolicy()
+	p.AllowCIDR("127.0.0.1")
+	p.Ports = []string{"443"}
+	_, err = p.Client(5 * time.Second).Get(srv.URL)
+	deny(err, "dial", url.DenyPort)
+
+	// Get checks the url before connecting
+	p.Ports = nil
+	// the query is sent as given; signed and order sensitive queries
+	// and a bare ? reach the server unchanged
+	var u url.URL
+	for _, path := range []string{"/path?sig=abc&b=2&a=1&x=%7e", "/path?", "/path/page.html"} {
+		u.Parse(srv.URL + path)
+		if res, err = p.Get(context.Background(), p.Client(5*time.Second), &u); err != nil || res.StatusCode != http.StatusOK {
+			t.Fatal(res, err)
+		}
+		res.Body.Close()
+		if requestURI != path {
+			t.Fatal("request uri", requestURI, "expect", path)
+		}
+	}
+	u.Parse("http://192.168.1.1:8080/")
+	_, err = p.Get(context.Background(), http.DefaultClient, &u)
+	deny(err, "url", url.DenyPrivate)
+
+}
+
+func TestPolicyCheck(t *testing.T) {
+
+	p := url.NewPolicy()
+	for _, v := range []struct {
+		URL    string
+		Reason url.DenyReason
+		OK     bool
+	}{
+		{"https://example.com/path", 0, true},
+		{"93.184.215.14", 0, true},
+		{"gopher://example.com/", url.DenyScheme, false},
+		{"http://127.0.0.1/", url.DenyPrivate, false},
+		{"http://[::1]:8080/", url.DenyPrivate, false},
+		{"http://169.254.169.254/latest/meta-data", url.DenyClass, false},
+		{"http://100.64.0.1/", url.DenyClass, false},
+		{"http://[64:ff9b::a00:1]/", url.DenyPrivate, false}, // nat64 10.0.0.1
+		{"http://[2002:7f00:1::]/", url.DenyPrivate, false},  // 6to4 127.0.0.1
+	} {
+		var u url.URL
+		u.Parse(v.URL)
+		err := p.CheckURL(&u)
+		var e *url.DenyError
+		if v.OK != (err == nil) || (!v.OK && (!errors.As(err, &e) || e.Reason != v.Reason)) {
+			t.Fatal(v.URL, err)
+		}
+	}
+
+	if err := p.DenyCIDR("bogus"); err == nil {
+		t.Fatal("expected cidr error")
+	}
+	if err := p.CheckIP(net.ParseIP("8.8.8.8")); err != nil {
+		t.Fatal(err)
+	}
+	err := p.CheckIP(net.ParseIP("10.0.0.1"))
+	if err == nil || !strings.Contains(err.Error(), "private address (private)") {
+		t.Fatal(err)
+	}
+
+	p.DenyCIDR("10.0.0.0/8")
+	if err := p.CheckIP(net.ParseIP("64:ff9b::a00:1")); !errors.As(err, new(*url.DenyError)) || err.(*url.DenyError).Reason != url.DenyList {
+		t.Fatal("embedded deny list", err)
+	}
+
+}
@@ -0,0 +1,309 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+/*
+
+	url.URL offline unwrapping of redirector and link-shim urls into the
+	final target; wrappers are tried in registration order and applied
+	recursively, reporting each hop
+
+	google        google.<tld>/url?q=|url=
+	facebook      l.facebook.com/l.php?u=, lm.facebook.com, l.messenger.com
+	safelinks     *.safelinks.protection.outlook.com/?url=
+	proofpoint    urldefense.proofpoint.com/v1|v2/url?u=, urldefense.com/v3/__url__;bytes!!
+	mimecast      *.mimecast.com|mimecastprotect.com/s/...?domain=; domain only
+	slack         slack-redir.net/link?url=
+	redirect      generic url|redirect|redirect_uri|next|target|dest|goto|... with
+	              an absolute url value
+
+	target, chain, err := url.Unwrap(&u)
+	for _, hop := range chain {
+		fmt.Println(hop.Name, hop.URL.String())
+	}
+
+	url.DefaultUnwrappers.Register("tracker", func(u *url.URL) (string, bool) { ... })
+
+*/
+
+// ErrUnwrap reports an unwrapped target that does not parse or a chain
+// over the MaxDepth of the Unwrappers
+var ErrUnwrap = errors.New("url: unwrap failed")
+
+// Unwrapper returns the target wrapped in the url, or false
+type Unwrapper func(u *URL) (target string, ok bool)
+
+// Hop is a step of an unwrap chain; the Unwrapper name and its target
+type Hop struct {
+	Name string
+	URL  URL
+}
+
+// Unwrappers is an ordered registry of Unwrapper; Register is not safe
+// for concurrent use with Unwrap
+type Unwrappers struct {
+	MaxDepth int // longest chain; default 8
+
+	names []string
+	fns   []Unwrapper
+}
+
+// DefaultUnwrappers holds the built in unwrappers used by Unwrap
+var DefaultUnwrappers = NewUnwrappers()
+
+func init() {
+	DefaultUnwrappers.Register("google", unwrapGoogle)
+	DefaultUnwrappers.Register("facebook", unwrapFacebook)
+	DefaultUnwrappers.Register("safelinks", unwrapSafeLinks)
+	DefaultUnwrappers.Register("proofpoint", unwrapProofpoint)
+	DefaultUnwrappers.Register("mimecast", unwrapMimecast)
+	DefaultUnwrappers.Register("slack", unwrapSlack)
+	DefaultUnwrappers.Register("redirect", unwrapRedirect)
+}
+
+// NewUnwrappers returns an empty registry
+func NewUnwrappers() *Unwrappers { return &Unwrappers{MaxDepth: 8} }
+
+// Register adds the unwrapper or replaces the one of the same name
+func (r *Unwrappers) Register(name string, fn Unwrapper) {
+	for i := range r.names {
+		if r.names[i] == name {
+			r.fns[i] = fn
+			return
+		}
+	}
+	r.names = append(r.names, name)
+	r.fns = append(r.fns, fn)
+}
+
+// Unwrap applies the default unwrappers; see Unwrappers.Unwrap
+func Unwrap(u *URL) (URL, []Hop, error) { return DefaultUnwrappers.Unwrap(u) }
+
+// Unwrap recursively unwraps the url returning the final target and the
+// chain of hops; a url that is not wrapped is returned as is with an
+// empty chain, and on error the last target reached is returned
+func (r *Unwrappers) Unwrap(u *URL) (URL, []Hop, error) {
+
+	var chain []Hop
+	current := *u
+next:
+	for {
+		for i, fn := range r.fns {
+			target, ok := fn(&current)
+			if !ok {
+				continue
+			}
+			var t URL
+			if !t.Parse(target) {
+				return current, chain, fmt.Errorf("%w: %s: %q", ErrUnwrap, r.names[i], target)
+			}
+			if t == current {
+				break // wraps itself
+			}
+			if len(chain) == r.MaxDepth {
+				return current, chain, fmt.Errorf("%w: chain over %d", ErrUnwrap, r.MaxDepth)
+			}
+			chain = append(chain, Hop{Name: r.names[i], URL: t})
+			current = t
+			continue next
+		}
+		return current, chain, nil
+	}
+}
+
+/*
+
+	built in unwrappers
+
+*/
+
+// urlPath returns the path of the url without the leading slash
+func urlPath(u *URL) string {
+	if len(u.Page) > 0 {
+		return u.Path + "/" + u.Page
+	}
+	return u.Path
+}
+
+// queryValue returns the first non empty query value of the keys
+// from the query as given
+func queryValue(u *URL, keys ...string) (string, bool) {
+	values, err := neturl.ParseQuery(u.query())
+	if err != nil {
+		return "", false
+	}
+	for _, key := range keys {
+		if v := values.Get(key); len(v) > 0 {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// isDomain reports if the host is the domain or a subdomain of it
+func isDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// absolute reports if the value looks like an absolute url
+func absolute(v string) bool {
+	return strings.Contains(v, "://") || strings.HasPrefix(v, "//") || strings.HasPrefix(v, "www.")
+}
+
+func unwrapGoogle(u *URL) (string, bool) {
+	apex, err := publicsuffix.EffectiveTLDPlusOne(u.Host)
+	if err != nil || !strings.HasPrefix(apex, "google.") || urlPath(u) != "url" {
+		return "", false
+	}
+	return queryValue(u, "q", "url")
+}
+
+func unwrapFacebook(u *URL) (string, bool) {
+	switch u.Host {
+	case "l.facebook.com", "lm.facebook.com", "l.messenger.com", "l.instagram.com":
+		if urlPath(u) == "l.php" || urlPath(u) == "" {
+			return queryValue(u, "u")
+		}
+	}
+	return "", false
+}
+
+func unwrapSafeLinks(u *URL) (string, bool) {
+	if !isDomain(u.Host, "safelinks.protection.outlook.com") {
+		return "", false
+	}
+	return queryValue(u, "url")
+}
+
+func unwrapSlack(u *URL) (string, bool) {
+	if u.Host != "slack-redir.net" || urlPath(u) != "link" {
+		return "", false
+	}
+	return queryValue(u, "url")
+}
+
+func unwrapMimecast(u *URL) (string, bool) {
+	if !isDomain(u.Host, "mimecast.com") && !isDomain(u.Host, "mimecastprotect.com") {
+		return "", false
+	}
+	return queryValue(u, "domain")
+}
+
+// redirectKeys are the generic redirect query parameters
+var redirectKeys = []string{"url", "redirect", "redirect_uri", "redirect_url", "redirectUrl",
+	"next", "target", "dest", "destination", "goto", "continue", "return", "returnTo", "return_to", "to", "link", "out"}
+
+func unwrapRedirect(u *URL) (string, bool) {
+	values, err := neturl.ParseQuery(u.query())
+	if err != nil {
+		return "", false
+	}
+	for _, key := range redirectKeys {
+		if v := values.Get(key); absolute(v) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// proofpoint v3 run lengths; a ** marker is followed by the run length
+// character, A=2 through _=65
+const proofpointRuns = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func unwrapProofpoint(u *URL) (string, bool) {
+
+	switch {
+	case u.Host == "urldefense.proofpoint.com":
+		v, ok := queryValue(u, "u")
+		if !ok {
+			return "", false
+		}
+		switch urlPath(u) {
+		case "v1/url":
+			return v, true
+		case "v2/url":
+			v = strings.NewReplacer("-", "%", "_", "/").Replace(v)
+			t, err := neturl.PathUnescape(v)
+			return t, err == nil
+		}
+
+	case u.Host == "urldefense.com":
+		s := urlPath(u) // the query as given is part of the wrapped url
+		if query := u.query(); len(query) > 0 || u.forceQuery {
+			s += "?" + query
+		}
+		if !strings.HasPrefix(s, "v3/__") {
+			return "", false
+		}
+		s = s[5:]
+		i := strings.Index(s, "__;")
+		if i < 0 {
+			return "", false
+		}
+		encoded, rest := s[:i], s[i+3:]
+		if j := strings.Index(rest, "!"); j > -1 {
+			rest = rest[:j]
+		}
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rest, "="))
+		if err != nil {
+			return "", false
+		}
+		return proofpointV3(encoded, []rune(string(b)))
+	}
+
+	return "", false
+}
+
+// proofpointV3 replaces the * and **<run> markers with the decoded bytes
+func proofpointV3(encoded string, chars []rune) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '*' {
+			b.WriteByte(encoded[i])
+			continue
+		}
+		n := 1
+		if i+2 < len(encoded) && encoded[i+1] == '*' {
+			if n = strings.IndexByte(proofpointRuns, encoded[i+2]) + 2; n < 2 {
+				return "", false
+			}
+			i += 2
+		}
+		if n > len(chars) {
+			return "", false
+		}
+		b.WriteString(string(chars[:n]))
+		chars = chars[n:]
+	}
+	return b.String(), true
+}
@@ -0,0 +1,96 @@
+// MIT License
+//
+// Copyright (c) 2020 zxdev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package url_test
+
+import (
+	"errors"
+	neturl "net/url"
+	"strings"
+	"testing"
+
+	"github.com/zxdev/url/v2"
+)
+
+func TestUnwrap(t *testing.T) {
+
+	q := neturl.QueryEscape
+	final := "https://example.com/path/page.html?a=1"
+	safelinks := "https://nam02.safelinks.protection.outlook.com/?url=" + q(final) + "&data=04%7C01&reserved=0"
+
+	for _, v := range []struct {
+		wrapped string
+		names   string
+		target  string
+	}{
+		{"https://www.google.com/url?sa=t&q=" + q(final), "google", final},
+		{"https://www.google.co.uk/url?url=" + q(final), "google", final},
+		{"https://l.facebook.com/l.php?u=" + q(final) + "&h=AT0", "facebook", final},
+		{safelinks, "safelinks", final},
+		{"https://urldefense.proofpoint.com/v2/url?u=https-3A__example.com_path_page.html-3Fa-3D1&d=DwMF&c=x", "proofpoint", final},
+		{"https://urldefense.proofpoint.com/v1/url?u=" + q(final) + "&k=abc", "proofpoint", final},
+		{"https://urldefense.com/v3/__https://example.com/p*x=1**A=22__;PyZ5!!ABC!def$", "proofpoint", "https://example.com/p?x=1&y=22"},
+		{"https://protect-eu.mimecast.com/s/AbCdEf?domain=example.com", "mimecast", "example.com"},
+		{"https://slack-redir.net/link?url=" + q(final), "slack", final},
+		{"https://track.example.net/click?id=7&redirect=" + q(final), "redirect", final},
+		{"https://www.google.com/url?q=" + q(safelinks), "google safelinks", final},
+		{"https://example.com/search?q=hello&next=/local", "", "https://example.com/search?q=hello&next=/local"},
+		{"https://urldefense.com/v3/__https://example.com/p?sig=x&b=2&a=1&c=%7e__;!!ABC!def$", "proofpoint", "https://example.com/p?sig=x&b=2&a=1&c=%7e"},
+		{"https://track.example.net/click?redirect=" + q("https://example.com/p?b=2&a=1"), "redirect", "https://example.com/p?b=2&a=1"},
+	} {
+		var u, expect url.URL
+		u.Parse(v.wrapped)
+		expect.Parse(v.target)
+		got, chain, err := url.Unwrap(&u)
+		var names []string
+		for _, hop := range chain {
+			names = append(names, hop.Name)
+		}
+		if err != nil || got != expect || got.Absolute() != expect.Absolute() || strings.Join(names, " ") != v.names {
+			t.Errorf("%s: %+v %v %v", v.wrapped, got, names, err)
+		}
+		if len(chain) > 0 && chain[len(chain)-1].URL != got {
+			t.Error(v.wrapped, chain)
+		}
+	}
+
+	// custom registry, depth limit and targets that do not parse
+	r := url.NewUnwrappers()
+	r.MaxDepth = 2
+	r.Register("loop", func(u *url.URL) (string, bool) {
+		return "a" + u.Host, true
+	})
+	var u url.URL
+	u.Parse("example.com")
+	got, chain, err := r.Unwrap(&u)
+	if !errors.Is(err, url.ErrUnwrap) || len(chain) != 2 || got.Host != "aaexample.com" {
+		t.Fatal(got, chain, err)
+	}
+	r.Register("loop", func(u *url.URL) (string, bool) { return "invalid", true })
+	if got, _, err = r.Unwrap(&u); !errors.Is(err, url.ErrUnwrap) || got != u {
+		t.Fatal(got, err)
+	}
+	r.Register("loop", func(u *url.URL) (string, bool) { return u.String(), true })
+	if got, chain, err = r.Unwrap(&u); err != nil || len(chain) != 0 || got != u {
+		t.Fatal(got, chain, err)
+	}
+}
@@ -23,23 +23,27 @@
 package url
 
 import (
-	"bufio"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	neturl "net/url"
+	"strconv"
 	"strings"
 
-	"github.com/zxdev/xxhash/v2"
 	"golang.org/x/net/idna"
 	"golang.org/x/net/publicsuffix"
 )
 
-// URL parse and validate url with type detection flags for IP|IDNA
+// URL parse and validate url with type detection flags for IP|IDNA;
+// Scheme and the canonical Query are retained but not part of String
 type URL struct {
 	Host, Port, Path, Page string
+	Scheme, Query          string
 	IP, IDNA               bool
 	noIDNA, ipv6           bool
+	forceQuery             bool   // defined but empty query; a bare ?
+	rawQuery               string // query as given to Parse
 }
 
 // puny converts idna `âbc.com` to `xn--bc-oia.com`
@@ -75,6 +79,44 @@ func (u *URL) String() string {
 	return host + "/" + path
 }
 
+// Absolute representation with the scheme, default http, and the query
+// as given to Parse including a bare ?; an empty path is the root path
+func (u *URL) Absolute() string {
+
+	var scheme = u.Scheme
+	if len(scheme) == 0 {
+		scheme = "http"
+	}
+
+	var host = u.Host
+	if u.ipv6 || strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if len(u.Port) > 0 {
+		host += ":" + u.Port
+	}
+
+	var path = u.Path
+	if len(u.Page) > 0 {
+		path += "/" + u.Page
+	}
+
+	url := scheme + "://" + host + "/" + path
+	if query := u.query(); len(query) > 0 || u.forceQuery {
+		url += "?" + query
+	}
+
+	return url
+}
+
+// query returns the query as given to Parse, else the canonical Query
+func (u *URL) query() string {
+	if len(u.rawQuery) > 0 {
+		return u.rawQuery
+	}
+	return u.Query
+}
+
 // Parse the url into consitituate parts. Set u.IP flag if
 // hostname is IPv4|6 and u.IDNA flag when domain converted
 func (u *URL) Parse(url string) bool {
@@ -82,18 +124,27 @@ func (u *URL) Parse(url string) bool {
 	var idx int
 	*u = URL{} // hard reset; avoid previous data
 
+	// reject opaque schemes; see ParseOpaque
+	if IsOpaque(url) {
+		return false
+	}
+
 	// strip query fragment
 	if idx = strings.Index(url, "#"); idx > 0 {
 		url = url[:idx]
 	}
 
-	// strip query segment
+	// strip query segment; retain canonical form
 	if idx = strings.Index(url, "?"); idx > 0 {
+		u.rawQuery = url[idx+1:]
+		u.Query = canonicalQuery(u.rawQuery)
+		u.forceQuery = len(u.rawQuery) == 0
 		url = url[:idx]
 	}
 
-	// strip schemes
+	// strip schemes; retain lowercase
 	if idx = strings.Index(url, "://"); idx > -1 {
+		u.Scheme = strings.ToLower(strings.TrimSpace(url[:idx]))
 		url = url[idx+3:]
 	}
 
@@ -104,14 +155,7 @@ func (u *URL) Parse(url string) bool {
 	}
 
 	// parse page or path
-	if len(u.Path) > 0 {
-		if idx = strings.LastIndex(u.Path, "/"); idx > -1 {
-			if strings.ContainsAny(u.Path[idx:], ".-_") {
-				u.Page = u.Path[idx+1:]
-				u.Path = u.Path[:idx]
-			}
-		}
-	}
+	u.Path, u.Page = splitPage(u.Path)
 
 	// standardize host to lowercase
 	url = strings.ToLower(url)
@@ -170,6 +214,27 @@ func (u *URL) Parse(url string) bool {
 	return true
 }
 
+// splitPage splits the trailing page from the path when the
+// last segment looks like a file; eg. path/logo.jpg
+func splitPage(path string) (string, string) {
+	if idx := strings.LastIndex(path, "/"); idx > -1 {
+		if strings.ContainsAny(path[idx:], ".-_") {
+			return path[:idx], path[idx+1:]
+		}
+	}
+	return path, ""
+}
+
+// canonicalQuery sorts the query by key and normalizes the escaping;
+// a query that does not decode is kept as is
+func canonicalQuery(query string) string {
+	values, err := neturl.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	return values.Encode()
+}
+
 /*
 
 	url.URL convenience helper functions
@@ -288,14 +353,15 @@ func IsPrivate(ip interface{}) (ok bool) {
 
 */
 
-// Parser reads io.Reader and parses into *url.URL
+// Parser reads io.Reader and parses into *url.URL; a Scanner that
+// skips invalid lines, see NewScanner for errors and statistics
 func Parser(r io.Reader) func(u *URL) bool {
-	scanner := bufio.NewScanner(r)
+	s := NewScanner(r)
 	return func(u *URL) bool {
-		if !scanner.Scan() {
+		if !s.Scan() {
 			return false
 		}
-		u.Parse(scanner.Text())
+		*u = s.URL()
 		return true
 	}
 }
@@ -311,14 +377,81 @@ func Parser(r io.Reader) func(u *URL) bool {
 
 */
 
+// Kind is a fingerprint kind; the key material a hash is generated from
+type Kind int
+
 // hash generator kind defination
 const (
-	Apex = iota
-	Host
-	Full
-	FullNoPage
+	Apex       Kind = iota // example.com
+	Host                   // www.example.com
+	Full                   // www.example.com:8080/path/logo.jpg
+	FullNoPage             // www.example.com:8080/path/
+	HostPort               // www.example.com:8080
+	SchemeHost             // https://www.example.com:8080; http when absent
+	PathOnly               // /path/logo.jpg
+	FullQuery              // www.example.com:8080/path/logo.jpg?a=1&b=2
+	TLD                    // com; public suffix
+	Subdomain              // www; labels left of the apex
+
+	pathPrefix Kind = 0x100 // PathPrefix base
+)
+
+// fingerprint errors
+var (
+	ErrKind  = errors.New("url: unknown fingerprint kind")
+	ErrNoKey = errors.New("url: no key material for kind")
 )
 
+// PathPrefix is the kind for the host and the leading n path
+// directories, eg. PathPrefix(1) www.example.com/path/
+func PathPrefix(n int) Kind {
+	if n < 0 || n > 0xff {
+		return -1 // invalid
+	}
+	return pathPrefix + Kind(n)
+}
+
+var kindNames = [...]string{"apex", "host", "full", "full-nopage", "host-port",
+	"scheme-host", "path", "full-query", "tld", "subdomain"}
+
+// String name of the kind; ParseKind reverses it
+func (k Kind) String() string {
+	switch {
+	case k >= 0 && int(k) < len(kindNames):
+		return kindNames[k]
+	case k >= pathPrefix && k <= pathPrefix+0xff:
+		return "path-prefix-" + strconv.Itoa(int(k-pathPrefix))
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ParseKind returns the Kind for a String name; the path prefix depth
+// is plain decimal digits as String writes it, without sign or leading zero
+func ParseKind(name string) (Kind, error) {
+	for i := range kindNames {
+		if kindNames[i] == name {
+			return Kind(i), nil
+		}
+	}
+	if digits := strings.TrimPrefix(name, "path-prefix-"); digits != name && isDigits(digits) &&
+		(digits == "0" || digits[0] != '0') {
+		if n, err := strconv.Atoi(digits); err == nil && PathPrefix(n) >= 0 {
+			return PathPrefix(n), nil
+		}
+	}
+	return -1, fmt.Errorf("%w: %q", ErrKind, name)
+}
+
+// isDigits reports a non-empty string of ascii decimal digits
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
 /*
 	3 orig  sub.example.com/path
 	3 apex  2883ba7dc9aa3289
@@ -333,91 +466,140 @@ const (
 	4 full- 90fab2c6396b011a
 */
 
-// FPHex64 generates a key based on the kind request
-func FPHex64(u *URL, kind int) (key string, ok bool) {
+// source returns the string material a kind fingerprint is generated from
+func source(u *URL, kind Kind) (s string, err error) {
 
 	switch kind {
-	case 0: // Apex
+	case Apex:
 		if apex, err := EffectiveTLDPlusOne(u); err == nil && len(apex) > 0 {
-			return fmt.Sprintf("%016x", xxhash.SSum(apex)), true
+			return apex, nil
 		}
 
-	case 1: // Host
+	case Host:
 		if len(u.Host) > 0 {
-			return fmt.Sprintf("%016x", xxhash.SSum(u.Host)), true
+			return u.Host, nil
 		}
 
-	case 3: // FullNopage
+	case FullQuery:
+		if len(u.Query) > 0 && len(u.Host) > 0 {
+			return u.String() + "?" + u.Query, nil
+		}
+		if s = u.String(); len(s) > 0 {
+			return s, nil
+		}
+
+	case FullNoPage:
 		if len(u.Page) > 0 {
 			page := u.Page
 			u.Page = ""
-			s := u.String() + "/"
+			s = u.String() + "/"
 			u.Page = page
-			return fmt.Sprintf("%016x", xxhash.SSum(s)), true
+			return s, nil
 		}
 		fallthrough
 
-	case 2: // Full
-		if s := u.String(); len(s) > 0 {
-			return fmt.Sprintf("%016x", xxhash.SSum(s)), true
+	case Full:
+		if s = u.String(); len(s) > 0 {
+			return s, nil
 		}
 
-	}
+	case HostPort, SchemeHost:
+		if len(u.Host) > 0 {
+			s = u.Host
+			if len(u.Port) > 0 {
+				if u.ipv6 {
+					s = "[" + s + "]"
+				}
+				s += ":" + u.Port
+			}
+			if kind == SchemeHost {
+				scheme := u.Scheme
+				if len(scheme) == 0 {
+					scheme = "http"
+				}
+				s = scheme + "://" + s
+			}
+			return s, nil
+		}
 
-	return
-}
+	case PathOnly:
+		if len(u.Host) > 0 {
+			s = "/" + u.Path
+			if len(u.Page) > 0 {
+				s += "/" + u.Page
+			}
+			return s, nil
+		}
 
-// FPMHex64 is a multiform xxhash64 fingerprint generator utility
-func FPMHex64(u *URL) (fp struct{ Apex, Host, Full, FullNoPage string }) {
+	case TLD:
+		if len(u.Host) > 0 && !u.IP {
+			tld, _ := publicsuffix.PublicSuffix(u.Host)
+			return tld, nil
+		}
 
-	fp.Apex, _ = FPHex64(u, 0)
-	fp.Host, _ = FPHex64(u, 1)
-	fp.Full, _ = FPHex64(u, 2)
-	fp.FullNoPage, _ = FPHex64(u, 3)
+	case Subdomain:
+		if apex, err := EffectiveTLDPlusOne(u); err == nil && !u.IP && len(apex) < len(u.Host) {
+			return strings.TrimSuffix(u.Host, "."+apex), nil
+		}
 
-	return
+	default:
+		if kind < pathPrefix || kind > pathPrefix+0xff {
+			return "", ErrKind
+		}
+		if len(u.Host) == 0 {
+			break
+		}
+		if dirs := directories(u); int(kind-pathPrefix) < len(dirs) {
+			return u.Host + dirs[kind-pathPrefix], nil
+		}
+	}
+
+	return "", ErrNoKey
 }
 
-// FPUint64 generates a key based on the kind request
-func FPUint64(u *URL, kind int) (key uint64, ok bool) {
+// directories returns the root and each ancestor directory of the
+// path with a trailing slash; a final segment without one is a page
+func directories(u *URL) []string {
 
-	switch kind {
-	case 0: // Apex
-		if apex, err := EffectiveTLDPlusOne(u); err == nil && len(apex) > 0 {
-			return xxhash.SSum(apex), true
-		}
+	path := "/" + u.Path
+	if len(u.Page) > 0 {
+		path += "/" + u.Page
+	}
 
-	case 1: // Host
-		if len(u.Host) > 0 {
-			return xxhash.SSum(u.Host), true
+	dirs := []string{"/"}
+	for i := 1; i < len(path); i++ {
+		if path[i] == '/' && path[i-1] != '/' {
+			dirs = append(dirs, path[:i+1])
 		}
+	}
 
-	case 3: // FullNopage
-		if len(u.Page) > 0 {
-			page := u.Page
-			u.Page = ""
-			s := u.String() + "/"
-			u.Page = page
-			return xxhash.SSum(s), true
-		}
-		fallthrough
+	return dirs
+}
 
-	case 2: // Full
-		if s := u.String(); len(s) > 0 {
-			return xxhash.SSum(s), true
-		}
-	}
+// FPHex64 generates a key based on the kind request
+func FPHex64(u *URL, kind Kind) (key string, err error) { return XXH64.Hex(u, kind) }
+
+// FPMHex64 is a multiform xxhash64 fingerprint generator utility
+func FPMHex64(u *URL) (fp struct{ Apex, Host, Full, FullNoPage string }) {
+
+	fp.Apex, _ = FPHex64(u, Apex)
+	fp.Host, _ = FPHex64(u, Host)
+	fp.Full, _ = FPHex64(u, Full)
+	fp.FullNoPage, _ = FPHex64(u, FullNoPage)
 
 	return
 }
 
+// FPUint64 generates a key based on the kind request
+func FPUint64(u *URL, kind Kind) (key uint64, err error) { return XXH64.Uint64(u, kind) }
+
 // FPMUint64 is a multiform xxhash64 fingerprint generator utility
 func FPMUint64(u *URL) (fp struct{ Apex, Host, Full, FullNoPage uint64 }) {
 
-	fp.Apex, _ = FPUint64(u, 0)
-	fp.Host, _ = FPUint64(u, 1)
-	fp.Full, _ = FPUint64(u, 2)
-	fp.FullNoPage, _ = FPUint64(u, 3)
+	fp.Apex, _ = FPUint64(u, Apex)
+	fp.Host, _ = FPUint64(u, Host)
+	fp.Full, _ = FPUint64(u, Full)
+	fp.FullNoPage, _ = FPUint64(u, FullNoPage)
 
 	return
 }
@@ -437,101 +619,29 @@ func FPMUint64(u *URL) (fp struct{ Apex, Host, Full, FullNoPage uint64 }) {
 */
 
 // FPHex256 is a sha256 fingerprint generator utility
-func FPHex256(u *URL, kind int) (key string, ok bool) {
-
-	h := sha256.New()
-
-	switch kind {
-	case 0: // Apex
-		if apex, err := EffectiveTLDPlusOne(u); err == nil && len(apex) > 0 {
-			h.Write([]byte(apex))
-			return fmt.Sprintf("%064x", h.Sum(nil)), true
-		}
-
-	case 1: // Host
-		if len(u.Host) > 0 {
-			h.Write([]byte(u.Host))
-			return fmt.Sprintf("%064x", h.Sum(nil)), true
-		}
-
-	case 3: // FullNopage
-		if len(u.Page) > 0 {
-			page := u.Page
-			u.Page = ""
-			s := u.String() + "/"
-			u.Page = page
-			h.Write([]byte(s))
-			return fmt.Sprintf("%064x", h.Sum(nil)), true
-		}
-		fallthrough
-
-	case 2: // Full
-		if s := u.String(); len(s) > 0 {
-			h.Write([]byte(s))
-			return fmt.Sprintf("%064x", h.Sum(nil)), true
-		}
-	}
-
-	return
-}
+func FPHex256(u *URL, kind Kind) (key string, err error) { return SHA256.Hex(u, kind) }
 
 // FPMHex256 is a multiform sha256 fingerprint generator utility
-func FPMHex256(u *URL, kind int) (fp struct{ Apex, Host, Full, FullNoPage string }) {
+func FPMHex256(u *URL, kind Kind) (fp struct{ Apex, Host, Full, FullNoPage string }) {
 
-	fp.Apex, _ = FPHex256(u, 0)
-	fp.Host, _ = FPHex256(u, 1)
-	fp.Full, _ = FPHex256(u, 2)
-	fp.FullNoPage, _ = FPHex256(u, 3)
+	fp.Apex, _ = FPHex256(u, Apex)
+	fp.Host, _ = FPHex256(u, Host)
+	fp.Full, _ = FPHex256(u, Full)
+	fp.FullNoPage, _ = FPHex256(u, FullNoPage)
 
 	return
 }
 
 // FPByte256 is a sha256 fingerpint generator utility
-func FPByte256(u *URL, kind int) (key []byte, ok bool) {
-
-	h := sha256.New()
-
-	switch kind {
-	case 0: // Apex
-		if apex, err := EffectiveTLDPlusOne(u); err == nil && len(apex) > 0 {
-			h.Write([]byte(apex))
-			return h.Sum(nil), true
-		}
-
-	case 1: // Host
-		if len(u.Host) > 0 {
-			h.Write([]byte(u.Host))
-			return h.Sum(nil), true
-		}
-
-	case 3: // FullNopage
-		if len(u.Page) > 0 {
-			page := u.Page
-			u.Page = ""
-			s := u.String() + "/"
-			u.Page = page
-			h.Write([]byte(s))
-			return h.Sum(nil), true
-		}
-		fallthrough
-
-	case 2: // Full
-		if s := u.String(); len(s) > 0 {
-			h.Write([]byte(s))
-			return h.Sum(nil), true
-		}
-	}
-
-	return
-}
+func FPByte256(u *URL, kind Kind) (key []byte, err error) { return SHA256.Sum(u, kind) }
 
 // FPMByte256 is a multiform sha256 fingerprint generator utility
-func FPMByte256(u *URL, kind int) (fp struct{ Apex, Host, Full, FullNoPage []byte }) {
+func FPMByte256(u *URL, kind Kind) (fp struct{ Apex, Host, Full, FullNoPage []byte }) {
 
-	fp.Apex, _ = FPByte256(u, 0)
-	fp.Host, _ = FPByte256(u, 1)
-	fp.Full, _ = FPByte256(u, 2)
-	fp.FullNoPage, _ = FPByte256(u, 3)
+	fp.Apex, _ = FPByte256(u, Apex)
+	fp.Host, _ = FPByte256(u, Host)
+	fp.Full, _ = FPByte256(u, Full)
+	fp.FullNoPage, _ = FPByte256(u, FullNoPage)
 
 	return
 }
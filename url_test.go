@@ -24,6 +24,11 @@ package url_test
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/fnv"
 	"testing"
 
 	"github.com/zxdev/url/v2"
@@ -102,14 +107,14 @@ func TestFPHex256(t *testing.T) {
 		t.Log(i, "orig ", v)
 
 		//kind, fp256 := url.FPSha256()
-		key, ok := url.FPHex256(&u, url.Apex)
-		t.Log(i, "apex ", key, ok)
-		key, ok = url.FPHex256(&u, url.Host)
-		t.Log(i, "host ", key, ok)
-		key, ok = url.FPHex256(&u, url.Full)
-		t.Log(i, "full ", key, ok)
-		key, ok = url.FPHex256(&u, url.FullNoPage)
-		t.Log(i, "full-", key, ok)
+		key, err := url.FPHex256(&u, url.Apex)
+		t.Log(i, "apex ", key, err)
+		key, err = url.FPHex256(&u, url.Host)
+		t.Log(i, "host ", key, err)
+		key, err = url.FPHex256(&u, url.Full)
+		t.Log(i, "full ", key, err)
+		key, err = url.FPHex256(&u, url.FullNoPage)
+		t.Log(i, "full-", key, err)
 		t.Log("---")
 	}
 }
@@ -121,18 +126,116 @@ func TestFPHex64(t *testing.T) {
 		t.Log(i, "orig ", v)
 
 		//kind, fp256 := url.FPSha256()
-		key, ok := url.FPHex64(&u, url.Apex)
-		t.Log(i, "apex ", key, ok)
-		key, ok = url.FPHex64(&u, url.Host)
-		t.Log(i, "host ", key, ok)
-		key, ok = url.FPHex64(&u, url.Full)
-		t.Log(i, "full ", key, ok)
-		key, ok = url.FPHex64(&u, url.FullNoPage)
-		t.Log(i, "full-", key, ok)
+		key, err := url.FPHex64(&u, url.Apex)
+		t.Log(i, "apex ", key, err)
+		key, err = url.FPHex64(&u, url.Host)
+		t.Log(i, "host ", key, err)
+		key, err = url.FPHex64(&u, url.Full)
+		t.Log(i, "full ", key, err)
+		key, err = url.FPHex64(&u, url.FullNoPage)
+		t.Log(i, "full-", key, err)
 		t.Log("---")
 	}
 }
 
+func TestFingerprinter(t *testing.T) {
+
+	var u url.URL
+	u.Parse("www.example.com/path/logo.jpg")
+
+	key, err := url.FPHex256(&u, url.Apex)
+	if err != nil || key != "a379a6f6eeafb9a55e378c118034e2751e682fab9f2d30ab13d2125586ce1947" {
+		t.Fatal("sha256 apex", key, err)
+	}
+	key, _ = url.FPHex256(&u, url.FullNoPage)
+	if key != "4138f765ee40d6e68fed6e7abd6978c4dacb7534cb0a39dcdb626c783a3ec330" {
+		t.Fatal("sha256 full-", key)
+	}
+
+	n, _ := url.FPUint64(&u, url.Host)
+	h, _ := url.FPHex64(&u, url.Host)
+	if x, _ := url.XXH64.Uint64(&u, url.Host); x != n || len(h) != 16 {
+		t.Fatal("xxhash64 mismatch", n, x, h)
+	}
+
+	f := url.NewFingerprinter(func() hash.Hash { return fnv.New64a() })
+	h64 := fnv.New64a()
+	h64.Write([]byte("www.example.com"))
+	if key, err := f.Uint64(&u, url.Host); err != nil || key != h64.Sum64() {
+		t.Fatal("fnv64a", key, err)
+	}
+
+	f = url.NewFingerprinter(sha1.New)
+	sum := sha1.Sum([]byte("example.com"))
+	if key, err := f.Hex(&u, url.Apex); err != nil || key != hex.EncodeToString(sum[:]) {
+		t.Fatal("sha1", key, err)
+	}
+
+	u.Parse("bad")
+	if _, err := f.Sum(&u, url.Host); err != url.ErrNoKey {
+		t.Fatal("expected no key for empty url")
+	}
+
+}
+
+// raw is a pass through hash.Hash exposing the kind key material
+type raw struct{ bytes.Buffer }
+
+func (r *raw) Sum(b []byte) []byte { return append(b, r.Bytes()...) }
+func (r *raw) Size() int           { return r.Len() }
+func (r *raw) BlockSize() int      { return 1 }
+
+func TestKinds(t *testing.T) {
+
+	var u url.URL
+	u.Parse("HTTPS://www.Example.co.uk:8080/path/level/logo.jpg?b=2&a=1#frag")
+	f := url.NewFingerprinter(func() hash.Hash { return new(raw) })
+	for _, v := range []struct {
+		Kind url.Kind
+		Name string
+		Key  string
+		Err  error
+	}{
+		{url.Apex, "apex", "example.co.uk", nil},
+		{url.Host, "host", "www.example.co.uk", nil},
+		{url.Full, "full", "www.example.co.uk:8080/path/level/logo.jpg", nil},
+		{url.FullNoPage, "full-nopage", "www.example.co.uk:8080/path/level/", nil},
+		{url.HostPort, "host-port", "www.example.co.uk:8080", nil},
+		{url.SchemeHost, "scheme-host", "https://www.example.co.uk:8080", nil},
+		{url.PathOnly, "path", "/path/level/logo.jpg", nil},
+		{url.FullQuery, "full-query", "www.example.co.uk:8080/path/level/logo.jpg?a=1&b=2", nil},
+		{url.TLD, "tld", "co.uk", nil},
+		{url.Subdomain, "subdomain", "www", nil},
+		{url.PathPrefix(0), "path-prefix-0", "www.example.co.uk/", nil},
+		{url.PathPrefix(2), "path-prefix-2", "www.example.co.uk/path/level/", nil},
+		{url.PathPrefix(3), "path-prefix-3", "", url.ErrNoKey},
+		{url.Kind(99), "kind(99)", "", url.ErrKind},
+	} {
+		key, err := f.Sum(&u, v.Kind)
+		if string(key) != v.Key || err != v.Err || v.Kind.String() != v.Name {
+			t.Fatal(v.Name, string(key), err)
+		}
+		if k, err := url.ParseKind(v.Name); v.Err != url.ErrKind && (err != nil || k != v.Kind) {
+			t.Fatal("parse kind", v.Name, k, err)
+		}
+	}
+
+	u.Parse("10.10.10.10/path")
+	if _, err := f.Sum(&u, url.Subdomain); err != url.ErrNoKey {
+		t.Fatal("ip subdomain", err)
+	}
+	if key, _ := f.Sum(&u, url.SchemeHost); string(key) != "http://10.10.10.10" {
+		t.Fatal("default scheme", string(key))
+	}
+	for _, name := range []string{"bogus", "path-prefix-+1", "path-prefix--1", "path-prefix- 1",
+		"path-prefix-01", "path-prefix-", "path-prefix-256", "path-prefix-1e2"} {
+		if _, err := url.ParseKind(name); !errors.Is(err, url.ErrKind) {
+			t.Fatal("parse kind", name, err)
+		}
+	}
+
+}
+
 func TestParser(t *testing.T) {
 	var buf bytes.Buffer
 	for i := range testSet {
@@ -148,10 +251,13 @@ func TestParser(t *testing.T) {
 func TestSB(t *testing.T) {
 
 	url, err := safebrowsing.ParseURL("a.example.com/path")
-	t.Log(url.Host, err)
-	t.Log(url.Hostname(), err)
+	if err != nil || url.Host != "a.example.com" || url.String() != "http://a.example.com/path" {
+		t.Fatal(url, err)
+	}
 
-	pattern, _ := safebrowsing.GeneratePatterns("a.b.c.example.comn/path/page")
-	t.Log(pattern)
+	pattern, err := safebrowsing.GeneratePatterns("a.b.c.example.comn/path/page")
+	if err != nil || len(pattern) != 12 || pattern[0] != "a.b.c.example.comn/" {
+		t.Fatal(pattern, err)
+	}
 
 }